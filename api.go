@@ -0,0 +1,201 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+)
+
+//================================================================//
+// REQUISIÇÕES E RESPOSTAS DA API
+//================================================================//
+
+// Códigos de erro retornados no corpo JSON das respostas de erro.
+const (
+	ErrCodeInvalidJSON      = "invalid_json"
+	ErrCodeValidation       = "validation_failed"
+	ErrCodeNotFound         = "not_found"
+	ErrCodeMethodNotAllowed = "method_not_allowed"
+	ErrCodeOperationRunning = "operation_running"
+	ErrCodeNoOperation      = "no_operation"
+	ErrCodePathForbidden    = "path_forbidden"
+	ErrCodeUnauthorized     = "unauthorized"
+	ErrCodeForbidden        = "forbidden"
+	ErrCodeCSRF             = "csrf_failed"
+	ErrCodeInternal         = "internal_error"
+	ErrCodeReportInUse      = "report_in_use"
+	ErrCodeProfileInUse     = "profile_in_use"
+)
+
+// maxRequestBody limita o corpo das requisições JSON.
+const maxRequestBody = 1 << 20
+
+// APIError é o corpo JSON de uma resposta de erro.
+type APIError struct {
+	Status  int    `json:"-"`
+	Code    string `json:"code"`
+	Message string `json:"message"`
+	Field   string `json:"field,omitempty"`
+}
+
+func (e *APIError) Error() string { return e.Message }
+
+func apiError(status int, code, message string) *APIError {
+	return &APIError{Status: status, Code: code, Message: message}
+}
+
+// validationError indica qual campo da requisição é inválido.
+func validationError(field, format string, args ...any) *APIError {
+	return &APIError{Status: http.StatusBadRequest, Code: ErrCodeValidation, Message: fmt.Sprintf(format, args...), Field: field}
+}
+
+// JobAccepted é a resposta 202 das requisições que iniciam uma operação.
+type JobAccepted struct {
+	JobID string `json:"job_id"`
+	Kind  string `json:"kind"`
+}
+
+// OperationStatus é a resposta de pausar, retomar e cancelar.
+type OperationStatus struct {
+	JobID  string `json:"job_id"`
+	Status string `json:"status"`
+}
+
+func writeJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
+
+// writeError responde com err em JSON. Erros conhecidos recebem o status e o
+// código correspondentes; os demais são erros internos. Erros de validação
+// devem ser criados com validationError para resultarem em 400.
+func writeError(w http.ResponseWriter, err error) {
+	var apiErr *APIError
+	switch {
+	case errors.As(err, &apiErr):
+	case errors.Is(err, errOperationRunning):
+		apiErr = apiError(http.StatusConflict, ErrCodeOperationRunning, err.Error())
+	case errors.Is(err, errOutsideRoots):
+		apiErr = apiError(http.StatusForbidden, ErrCodePathForbidden, err.Error())
+	case errors.Is(err, errNotFound), errors.Is(err, os.ErrNotExist):
+		apiErr = apiError(http.StatusNotFound, ErrCodeNotFound, err.Error())
+	default:
+		apiErr = apiError(http.StatusInternalServerError, ErrCodeInternal, err.Error())
+	}
+	writeJSON(w, apiErr.Status, apiErr)
+}
+
+// errNotFound é envolvido pelos erros de perfis, agendamentos e relatórios inexistentes.
+var errNotFound = errors.New("não encontrado")
+
+// decodeJSON lê o corpo da requisição em v, rejeitando campos desconhecidos.
+// Um corpo vazio equivale a um objeto vazio.
+func decodeJSON(w http.ResponseWriter, r *http.Request, v any) error {
+	dec := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxRequestBody))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(v); err != nil && !errors.Is(err, io.EOF) {
+		return apiError(http.StatusBadRequest, ErrCodeInvalidJSON, "JSON inválido: "+err.Error())
+	}
+	return nil
+}
+
+// allowMethods rejeita com 405 as requisições cujo método não esteja em methods.
+func allowMethods(h http.HandlerFunc, methods ...string) http.HandlerFunc {
+	allowed := strings.Join(methods, ", ")
+	return func(w http.ResponseWriter, r *http.Request) {
+		for _, m := range methods {
+			if r.Method == m {
+				h(w, r)
+				return
+			}
+		}
+		w.Header().Set("Allow", allowed)
+		writeJSON(w, http.StatusMethodNotAllowed, apiError(http.StatusMethodNotAllowed, ErrCodeMethodNotAllowed,
+			fmt.Sprintf("Método %s não permitido; use %s.", r.Method, allowed)))
+	}
+}
+
+// startJob registra uma operação do tipo kind, aplica os limites informados
+// (nil mantém os atuais) e a executa em segundo plano, respondendo 202 com o ID.
+func startJob(w http.ResponseWriter, kind string, limits *ThrottleSettings, op func(ctx context.Context) error) {
+	ctx, cancel := context.WithCancel(context.Background())
+	jobID, ok := state.TryStart(kind, cancel)
+	if !ok {
+		cancel()
+		writeError(w, errOperationRunning)
+		return
+	}
+	if limits != nil {
+		throttle.Configure(*limits)
+	}
+	go runOperation(ctx, op)
+	writeJSON(w, http.StatusAccepted, JobAccepted{JobID: jobID, Kind: kind})
+}
+
+//================================================================//
+// VALIDAÇÕES
+//================================================================//
+
+// validateDirectory verifica se path está nos diretórios permitidos, existe e
+// é um diretório, retornando o caminho canonicalizado.
+func validateDirectory(field, path string) (string, error) {
+	if strings.TrimSpace(path) == "" {
+		return "", validationError(field, "%s é obrigatório", field)
+	}
+	resolved, err := sandbox.Resolve(path)
+	if err != nil {
+		return "", err
+	}
+	info, err := os.Stat(resolved)
+	if err != nil {
+		return "", validationError(field, "diretório %s não encontrado", path)
+	}
+	if !info.IsDir() {
+		return "", validationError(field, "%s não é um diretório", path)
+	}
+	return resolved, nil
+}
+
+// validateCollectionReport verifica se o relatório de coleta existe e é do tipo esperado.
+func validateCollectionReport(field, name, wantType string) error {
+	path, err := reportPath(collectedDir, name)
+	if err != nil {
+		return validationError(field, "%v", err)
+	}
+	header, err := readCollectionHeader(path)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return fmt.Errorf("relatório %s %w", name, errNotFound)
+		}
+		return validationError(field, "relatório %s ilegível: %v", name, err)
+	}
+	if header.Type != wantType {
+		return validationError(field, "relatório %s é do tipo %q; esperado %q", name, header.Type, wantType)
+	}
+	return nil
+}
+
+// validateComparisonReport verifica se o relatório de comparação existe e informa origem e destino.
+func validateComparisonReport(field, name string) error {
+	path, err := reportPath(comparisonDir, name)
+	if err != nil {
+		return validationError(field, "%v", err)
+	}
+	source, dest, err := readComparisonRoots(path)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return fmt.Errorf("relatório %s %w", name, errNotFound)
+		}
+		return validationError(field, "relatório %s ilegível: %v", name, err)
+	}
+	if source == "" || dest == "" {
+		return validationError(field, "relatório %s não informa os diretórios de origem e destino", name)
+	}
+	return nil
+}
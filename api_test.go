@@ -0,0 +1,137 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestDecodeJSON(t *testing.T) {
+	type request struct {
+		Name string `json:"name"`
+	}
+	tests := []struct {
+		name string
+		body string
+		want string // nome decodificado ou, se começar com "erro:", trecho da mensagem
+	}{
+		{"objeto", `{"name":"a"}`, "a"},
+		{"corpo vazio", ``, ""},
+		{"campo desconhecido", `{"name":"a","extra":1}`, `erro:unknown field "extra"`},
+		{"malformado", `{"name":`, "erro:JSON inválido"},
+		{"tipo errado", `{"name":1}`, "erro:JSON inválido"},
+		{"grande demais", `{"name":"` + strings.Repeat("x", maxRequestBody) + `"}`, "erro:too large"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodPost, "/", strings.NewReader(tt.body))
+			var req request
+			err := decodeJSON(httptest.NewRecorder(), r, &req)
+			wantErr, isErr := strings.CutPrefix(tt.want, "erro:")
+			if !isErr {
+				if err != nil || req.Name != tt.want {
+					t.Errorf("decodeJSON = %q, %v; esperado %q", req.Name, err, tt.want)
+				}
+				return
+			}
+			var apiErr *APIError
+			if !errors.As(err, &apiErr) || apiErr.Status != http.StatusBadRequest || apiErr.Code != ErrCodeInvalidJSON {
+				t.Fatalf("erro = %#v, esperado %s", err, ErrCodeInvalidJSON)
+			}
+			if !strings.Contains(apiErr.Message, wantErr) {
+				t.Errorf("mensagem %q não contém %q", apiErr.Message, wantErr)
+			}
+		})
+	}
+}
+
+func TestWriteError(t *testing.T) {
+	tests := []struct {
+		err    error
+		status int
+		code   string
+	}{
+		{validationError("name", "nome inválido"), http.StatusBadRequest, ErrCodeValidation},
+		{apiError(http.StatusConflict, ErrCodeReportInUse, "em uso"), http.StatusConflict, ErrCodeReportInUse},
+		{fmt.Errorf("iniciar: %w", errOperationRunning), http.StatusConflict, ErrCodeOperationRunning},
+		{fmt.Errorf("origem: %w", errOutsideRoots), http.StatusForbidden, ErrCodePathForbidden},
+		{fmt.Errorf("perfil %q %w", "p", errNotFound), http.StatusNotFound, ErrCodeNotFound},
+		{&os.PathError{Op: "open", Path: "x", Err: os.ErrNotExist}, http.StatusNotFound, ErrCodeNotFound},
+		{errors.New("falha qualquer"), http.StatusInternalServerError, ErrCodeInternal},
+	}
+	for _, tt := range tests {
+		w := httptest.NewRecorder()
+		writeError(w, tt.err)
+		var body APIError
+		if err := json.NewDecoder(w.Body).Decode(&body); err != nil {
+			t.Fatal(err)
+		}
+		if w.Code != tt.status || body.Code != tt.code || body.Message != tt.err.Error() {
+			t.Errorf("writeError(%v) = %d %+v, esperado %d %s", tt.err, w.Code, body, tt.status, tt.code)
+		}
+	}
+}
+
+// TestAPIv1Errors percorre as rotas da API v1, sem autenticação, com
+// requisições inválidas.
+func TestAPIv1Errors(t *testing.T) {
+	base, err := filepath.EvalSymlinks(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	savedSandbox, savedProfiles := sandbox, profiles
+	sandbox = &PathSandbox{roots: []string{base}}
+	profiles = newProfileStore(filepath.Join(base, "profiles.json"))
+	t.Cleanup(func() { sandbox, profiles = savedSandbox, savedProfiles })
+	mux := http.NewServeMux()
+	registerAPIv1(mux)
+
+	outside := filepath.Dir(base)
+	tests := []struct {
+		name, method, path, body string
+		status                   int
+		code, field              string
+	}{
+		{"campo desconhecido", "POST", "/api/v1/profiles",
+			fmt.Sprintf(`{"name":"p","source_path":%q,"dest_path":%q,"bogus":true}`, base, base),
+			http.StatusBadRequest, ErrCodeInvalidJSON, ""},
+		{"campo obrigatório ausente", "POST", "/api/v1/profiles", `{"name":"p"}`,
+			http.StatusBadRequest, ErrCodeValidation, "name"},
+		{"valor inválido", "POST", "/api/v1/profiles",
+			fmt.Sprintf(`{"name":"p","source_path":%q,"dest_path":%q,"hash_mode":"md5"}`, base, base),
+			http.StatusBadRequest, ErrCodeValidation, "hash_mode"},
+		{"origem fora dos diretórios permitidos", "POST", "/api/v1/profiles",
+			fmt.Sprintf(`{"name":"p","source_path":%q,"dest_path":%q}`, outside, base),
+			http.StatusForbidden, ErrCodePathForbidden, ""},
+		{"perfil inexistente", "GET", "/api/v1/profiles/nada", "", http.StatusNotFound, ErrCodeNotFound, ""},
+		{"nome divergente", "PUT", "/api/v1/profiles/a", `{"name":"b"}`, http.StatusBadRequest, ErrCodeValidation, "name"},
+		{"tipo de operação ausente", "POST", "/api/v1/jobs", `{}`, http.StatusBadRequest, ErrCodeValidation, "kind"},
+		{"tipo de operação inválido", "POST", "/api/v1/jobs", `{"kind":"format"}`, http.StatusBadRequest, ErrCodeValidation, "kind"},
+		{"corpo malformado", "POST", "/api/v1/jobs", `{"kind":`, http.StatusBadRequest, ErrCodeInvalidJSON, ""},
+		{"relatório com caminho", "GET", "/api/v1/reports/collection/..%2Fconfig", "", http.StatusBadRequest, ErrCodeValidation, "name"},
+		{"tipo de relatório inexistente", "GET", "/api/v1/reports/outro/a.json", "", http.StatusNotFound, ErrCodeNotFound, ""},
+		{"método não permitido", "DELETE", "/api/v1/openapi.json", "", http.StatusMethodNotAllowed, ErrCodeMethodNotAllowed, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			mux.ServeHTTP(w, httptest.NewRequest(tt.method, tt.path, strings.NewReader(tt.body)))
+			var body APIError
+			if err := json.NewDecoder(w.Body).Decode(&body); err != nil {
+				t.Fatalf("corpo de erro inválido: %v", err)
+			}
+			if w.Code != tt.status || body.Code != tt.code || body.Field != tt.field {
+				t.Errorf("%s %s = %d %+v, esperado %d %s (campo %q)", tt.method, tt.path, w.Code, body, tt.status, tt.code, tt.field)
+			}
+		})
+	}
+	if len(profiles.List()) != 0 {
+		t.Errorf("perfis salvos por requisições inválidas: %+v", profiles.List())
+	}
+}
@@ -0,0 +1,445 @@
+package main
+
+import (
+	"bytes"
+	"compress/gzip"
+	_ "embed"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"iter"
+	"math"
+	"net/http"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+//================================================================//
+// API REST v1
+//================================================================//
+
+//go:embed api/openapi.json
+var openAPISpec []byte
+
+// reportDirs associa cada tipo de relatório da API ao seu diretório de saída.
+var reportDirs = map[string]string{
+	"collection": collectedDir,
+	"comparison": comparisonDir,
+	"copy":       copyDir,
+}
+
+// reportKinds são as chaves de reportDirs, na ordem em que os diretórios são consultados.
+var reportKinds = []string{"collection", "comparison", "copy"}
+
+// jobStarters associa cada tipo de operação ao handler que a inicia. Os
+// handlers são os mesmos das rotas antigas (/collect, /compare, ...).
+var jobStarters = map[string]http.HandlerFunc{
+	"collect": handleCollect,
+	"compare": handleCompare,
+	"copy":    handleCopy,
+	"sync":    handleSync,
+	"watch":   handleWatch,
+	"cleanup": handleCleanup,
+}
+
+// JobDetails é um registro do histórico acrescido do progresso, se a operação estiver em andamento.
+type JobDetails struct {
+	JobRecord
+	Progress *WSMessage `json:"progress,omitempty"`
+}
+
+// ReportInfo descreve um relatório salvo.
+type ReportInfo struct {
+	Kind       string    `json:"kind"`
+	Name       string    `json:"name"`
+	Size       int64     `json:"size"`
+	ModifiedAt time.Time `json:"modified_at"`
+	Compressed bool      `json:"compressed,omitempty"`
+}
+
+// registerAPIv1 registra as rotas de /api/v1/.
+func registerAPIv1(mux *http.ServeMux) {
+	get, post, put, del := http.MethodGet, http.MethodPost, http.MethodPut, http.MethodDelete
+	mux.HandleFunc("/api/v1/openapi.json", allowMethods(handleOpenAPI, get))
+	mux.HandleFunc("/api/v1/i18n", allowMethods(handleI18n, get))
+
+	mux.HandleFunc("/api/v1/jobs", allowMethods(handleJobsV1, get, post))
+	mux.HandleFunc("/api/v1/jobs/{id}", allowMethods(handleJobV1, get))
+	mux.HandleFunc("/api/v1/jobs/{id}/log", allowMethods(handleJobLog, get))
+	mux.HandleFunc("/api/v1/jobs/{id}/pause", allowMethods(activeJob(handlePause), post))
+	mux.HandleFunc("/api/v1/jobs/{id}/resume", allowMethods(activeJob(handleResume), post))
+	mux.HandleFunc("/api/v1/jobs/{id}/cancel", allowMethods(activeJob(handleCancel), post))
+
+	mux.HandleFunc("/api/v1/reports", allowMethods(handleReportsV1, get))
+	mux.HandleFunc("/api/v1/reports/{kind}/{name}", allowMethods(handleReportV1, get, del))
+
+	mux.HandleFunc("/api/v1/profiles", allowMethods(handleProfiles, get, post))
+	mux.HandleFunc("/api/v1/profiles/{name}", allowMethods(handleProfileV1, get, put, del))
+
+	mux.HandleFunc("/api/v1/schedules", allowMethods(handleSchedules, get, post))
+	mux.HandleFunc("/api/v1/schedules/{name}", allowMethods(handleScheduleV1, get, del))
+
+	mux.HandleFunc("/api/v1/settings/throttle", allowMethods(handleThrottle, get, put))
+	mux.HandleFunc("/api/v1/settings/allowed-roots", allowMethods(handleAllowedRootsV1, get))
+	mux.HandleFunc("/api/v1/fs/list", allowMethods(handleListDir, get))
+	mux.HandleFunc("/api/v1/watch", allowMethods(handleWatch, get))
+}
+
+func handleOpenAPI(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Write(openAPISpec)
+}
+
+// handleJobsV1 lista o histórico de operações, da mais recente para a mais
+// antiga (GET), ou inicia uma nova (POST). O corpo do POST traz o tipo em
+// "kind" e, nos demais campos, a requisição do handler correspondente.
+func handleJobsV1(w http.ResponseWriter, r *http.Request) {
+	if r.Method == http.MethodGet {
+		writeJSON(w, http.StatusOK, jobHistory.List())
+		return
+	}
+
+	var body map[string]json.RawMessage
+	if err := decodeJSON(w, r, &body); err != nil {
+		writeError(w, err)
+		return
+	}
+	var kind string
+	if raw, ok := body["kind"]; ok {
+		if err := json.Unmarshal(raw, &kind); err != nil {
+			writeError(w, validationError("kind", "kind deve ser um texto"))
+			return
+		}
+	}
+	if kind == "" {
+		writeError(w, validationError("kind", "kind é obrigatório"))
+		return
+	}
+	start, ok := jobStarters[kind]
+	if !ok {
+		writeError(w, validationError("kind", "tipo de operação %q inválido; use %s", kind, strings.Join(jobKinds(), ", ")))
+		return
+	}
+	delete(body, "kind")
+	params, err := json.Marshal(body)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	r.Body = io.NopCloser(bytes.NewReader(params))
+	r.ContentLength = int64(len(params))
+	start(w, r)
+}
+
+// jobKinds lista, em ordem alfabética, os tipos de operação aceitos por POST /api/v1/jobs.
+func jobKinds() []string {
+	kinds := make([]string, 0, len(jobStarters))
+	for kind := range jobStarters {
+		kinds = append(kinds, kind)
+	}
+	sort.Strings(kinds)
+	return kinds
+}
+
+// handleJobV1 consulta uma operação.
+func handleJobV1(w http.ResponseWriter, r *http.Request) {
+	id := r.PathValue("id")
+	rec, ok := jobHistory.Get(id)
+	if !ok {
+		writeError(w, apiError(http.StatusNotFound, ErrCodeNotFound, fmt.Sprintf("operação %s não encontrada", id)))
+		return
+	}
+	details := JobDetails{JobRecord: rec}
+	if id == state.ActiveJobID() {
+		snapshot := state.Snapshot(hub.ProgressText())
+		details.Progress = &snapshot
+	}
+	writeJSON(w, http.StatusOK, details)
+}
+
+// activeJob garante que a ação se aplica à operação em andamento.
+func activeJob(h http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if id := r.PathValue("id"); id != state.ActiveJobID() {
+			writeError(w, apiError(http.StatusConflict, ErrCodeNoOperation, fmt.Sprintf("A operação %s não está em andamento.", id)))
+			return
+		}
+		h(w, r)
+	}
+}
+
+// handleReportsV1 lista os relatórios salvos, opcionalmente filtrados por ?kind=.
+func handleReportsV1(w http.ResponseWriter, r *http.Request) {
+	kinds := reportKinds
+	if kind := r.URL.Query().Get("kind"); kind != "" {
+		if _, ok := reportDirs[kind]; !ok {
+			writeError(w, validationError("kind", "tipo de relatório inválido: %q", kind))
+			return
+		}
+		kinds = []string{kind}
+	}
+	writeJSON(w, http.StatusOK, listReports(kinds...))
+}
+
+// listReports lista os relatórios salvos dos tipos informados, do mais recente
+// para o mais antigo. Relatórios ainda em gravação (.tmp) são omitidos.
+func listReports(kinds ...string) []ReportInfo {
+	reports := []ReportInfo{}
+	for _, kind := range kinds {
+		entries, err := os.ReadDir(reportDirs[kind])
+		if err != nil {
+			continue
+		}
+		for _, e := range entries {
+			info, err := e.Info()
+			if err != nil || !info.Mode().IsRegular() || strings.HasSuffix(e.Name(), ".tmp") {
+				continue
+			}
+			reports = append(reports, ReportInfo{Kind: kind, Name: e.Name(), Size: info.Size(), ModifiedAt: info.ModTime(),
+				Compressed: strings.HasSuffix(e.Name(), ".gz")})
+		}
+	}
+	sort.Slice(reports, func(i, j int) bool { return reports[i].ModifiedAt.After(reports[j].ModifiedAt) })
+	return reports
+}
+
+// CollectionPage é uma página das entradas de um relatório de coleta.
+type CollectionPage struct {
+	Header     CollectionHeader `json:"header"`
+	Files      []FileMetadata   `json:"files"`
+	NextOffset int              `json:"next_offset,omitempty"` // ausente na última página
+}
+
+const (
+	defaultPageSize = 100
+	maxPageSize     = 1000
+)
+
+// handleReportV1 envia (GET) ou remove (DELETE) um relatório. Para relatórios
+// de coleta, ?offset= e ?limit= retornam uma página das entradas em JSON, lida
+// do relatório sem carregá-lo inteiro.
+func handleReportV1(w http.ResponseWriter, r *http.Request) {
+	kind := r.PathValue("kind")
+	dir, ok := reportDirs[kind]
+	if !ok {
+		writeError(w, fmt.Errorf("tipo de relatório %q %w", kind, errNotFound))
+		return
+	}
+	if r.Method == http.MethodDelete {
+		handleDeleteReport(w, r, kind)
+		return
+	}
+	path, err := reportPath(dir, r.PathValue("name"))
+	if err != nil {
+		writeError(w, validationError("name", "%v", err))
+		return
+	}
+	q := r.URL.Query()
+	if kind == "collection" && (q.Has("offset") || q.Has("limit")) {
+		serveCollectionPage(w, r, path)
+		return
+	}
+	file, err := os.Open(path)
+	if err != nil {
+		writeError(w, fmt.Errorf("relatório %s %w", r.PathValue("name"), errNotFound))
+		return
+	}
+	defer file.Close()
+	info, err := file.Stat()
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	if strings.HasSuffix(strings.TrimSuffix(path, ".gz"), ".jsonl") {
+		w.Header().Set("Content-Type", "application/x-ndjson")
+	} else {
+		w.Header().Set("Content-Type", "application/json")
+	}
+	if !isGzipFile(file) {
+		http.ServeContent(w, r, "", info.ModTime(), file)
+		return
+	}
+	// Relatórios compactados vão como estão para clientes que aceitam gzip e
+	// são descompactados para os demais.
+	w.Header().Add("Vary", "Accept-Encoding")
+	if acceptsGzip(r.Header.Values("Accept-Encoding")) {
+		w.Header().Set("Content-Encoding", "gzip")
+		http.ServeContent(w, r, "", info.ModTime(), file)
+		return
+	}
+	gz, err := gzip.NewReader(file)
+	if err != nil {
+		writeError(w, apiError(http.StatusInternalServerError, ErrCodeInternal, "relatório ilegível: "+err.Error()))
+		return
+	}
+	defer gz.Close()
+	io.Copy(w, gz)
+}
+
+// qualityValues percorre os itens de cabeçalhos como Accept-Encoding e
+// Accept-Language, na ordem em que aparecem, com seus pesos (q). Itens sem q
+// pesam 1; pesos inválidos ou fora de [0, 1] valem 0.
+func qualityValues(headers []string) iter.Seq2[string, float64] {
+	return func(yield func(string, float64) bool) {
+		for _, header := range headers {
+			for _, item := range strings.Split(header, ",") {
+				value, params, _ := strings.Cut(item, ";")
+				value = strings.TrimSpace(value)
+				if value == "" {
+					continue
+				}
+				q := 1.0
+				for _, param := range strings.Split(params, ";") {
+					name, v, ok := strings.Cut(strings.TrimSpace(param), "=")
+					if ok && strings.EqualFold(strings.TrimSpace(name), "q") {
+						parsed, err := strconv.ParseFloat(strings.TrimSpace(v), 64)
+						if err != nil || parsed < 0 || parsed > 1 {
+							parsed = 0
+						}
+						q = parsed
+					}
+				}
+				if !yield(value, q) {
+					return
+				}
+			}
+		}
+	}
+}
+
+// acceptsGzip indica se os cabeçalhos Accept-Encoding aceitam gzip (ou x-gzip)
+// com qualidade maior que zero. "*" vale para gzip quando ele não é citado.
+func acceptsGzip(headers []string) bool {
+	gzipQ, anyQ := -1.0, -1.0
+	for coding, q := range qualityValues(headers) {
+		switch strings.ToLower(coding) {
+		case "gzip", "x-gzip":
+			gzipQ = max(gzipQ, q)
+		case "*":
+			anyQ = max(anyQ, q)
+		}
+	}
+	if gzipQ >= 0 {
+		return gzipQ > 0
+	}
+	return anyQ > 0
+}
+
+func serveCollectionPage(w http.ResponseWriter, r *http.Request, path string) {
+	offset, err := queryInt(r, "offset", 0, 0, math.MaxInt)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	limit, err := queryInt(r, "limit", defaultPageSize, 1, maxPageSize)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	report, err := openCollectionReport(path)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			err = fmt.Errorf("relatório %s %w", r.PathValue("name"), errNotFound)
+		}
+		writeError(w, err)
+		return
+	}
+	defer report.Close()
+
+	page := CollectionPage{Header: report.Header, Files: []FileMetadata{}}
+	i := 0
+	for f, err := range report.Files() {
+		if err != nil {
+			writeError(w, apiError(http.StatusInternalServerError, ErrCodeInternal, "relatório ilegível: "+err.Error()))
+			return
+		}
+		if i >= offset+limit {
+			page.NextOffset = i
+			break
+		}
+		if i >= offset {
+			page.Files = append(page.Files, f)
+		}
+		i++
+	}
+	writeJSON(w, http.StatusOK, page)
+}
+
+// queryInt lê um parâmetro inteiro da URL, entre min e max.
+func queryInt(r *http.Request, name string, def, min, max int) (int, error) {
+	raw := r.URL.Query().Get(name)
+	if raw == "" {
+		return def, nil
+	}
+	v, err := strconv.Atoi(raw)
+	if err != nil || v < min || v > max {
+		return 0, validationError(name, "%s deve ser um inteiro entre %d e %d", name, min, max)
+	}
+	return v, nil
+}
+
+// handleProfileV1 consulta, substitui ou exclui o perfil indicado no caminho.
+func handleProfileV1(w http.ResponseWriter, r *http.Request) {
+	name := r.PathValue("name")
+	switch r.Method {
+	case http.MethodGet:
+		p, ok := profiles.Get(name)
+		if !ok {
+			writeError(w, fmt.Errorf("perfil %q %w", name, errNotFound))
+			return
+		}
+		writeJSON(w, http.StatusOK, p)
+	case http.MethodPut:
+		var req Profile
+		if err := decodeJSON(w, r, &req); err != nil {
+			writeError(w, err)
+			return
+		}
+		if req.Name != "" && req.Name != name {
+			writeError(w, validationError("name", "o nome do corpo (%q) difere do caminho (%q)", req.Name, name))
+			return
+		}
+		req.Name = name
+		saved, err := profiles.Put(req)
+		if err != nil {
+			writeError(w, err)
+			return
+		}
+		writeJSON(w, http.StatusOK, saved)
+	case http.MethodDelete:
+		if err := profiles.Delete(name); err != nil {
+			writeError(w, err)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
+
+// handleScheduleV1 consulta ou exclui o agendamento indicado no caminho.
+func handleScheduleV1(w http.ResponseWriter, r *http.Request) {
+	name := r.PathValue("name")
+	switch r.Method {
+	case http.MethodGet:
+		for _, sc := range scheduler.List() {
+			if sc.Name == name {
+				writeJSON(w, http.StatusOK, sc)
+				return
+			}
+		}
+		writeError(w, fmt.Errorf("agendamento %q %w", name, errNotFound))
+	case http.MethodDelete:
+		if err := scheduler.Delete(name); err != nil {
+			writeError(w, err)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
+
+func handleAllowedRootsV1(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, map[string][]string{"roots": sandbox.Roots()})
+}
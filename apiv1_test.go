@@ -0,0 +1,31 @@
+package main
+
+import "testing"
+
+func TestAcceptsGzip(t *testing.T) {
+	tests := []struct {
+		headers []string
+		want    bool
+	}{
+		{nil, false},
+		{[]string{"gzip"}, true},
+		{[]string{"gzip, deflate, br"}, true},
+		{[]string{"GZIP"}, true},
+		{[]string{"x-gzip"}, true},
+		{[]string{"gzip;q=0"}, false},
+		{[]string{"gzip; q=0.000"}, false},
+		{[]string{"gzip;q=0.5"}, true},
+		{[]string{"deflate", "gzip;q=1"}, true},
+		{[]string{"*"}, true},
+		{[]string{"*;q=0"}, false},
+		{[]string{"gzip;q=0, *"}, false},
+		{[]string{"identity, *;q=0"}, false},
+		{[]string{"br, notgzip"}, false},
+		{[]string{"gzip;q=abc"}, false},
+	}
+	for _, tt := range tests {
+		if got := acceptsGzip(tt.headers); got != tt.want {
+			t.Errorf("acceptsGzip(%q) = %v, esperado %v", tt.headers, got, tt.want)
+		}
+	}
+}
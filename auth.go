@@ -0,0 +1,543 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+//================================================================//
+// AUTENTICAÇÃO E AUTORIZAÇÃO
+//================================================================//
+
+// Papéis: "viewer" apenas consulta; "operator" também inicia e altera operações.
+const (
+	RoleViewer   = "viewer"
+	RoleOperator = "operator"
+)
+
+const (
+	sessionCookie   = "gosync_session"
+	sessionLifetime = 12 * time.Hour
+	csrfHeader      = "X-CSRF-Token"
+	// bcryptCost fica acima do mínimo de 10 recomendado pela OWASP.
+	bcryptCost = 12
+)
+
+// AuthUser é um usuário local; a senha é guardada como hash bcrypt.
+type AuthUser struct {
+	Username     string `json:"username"`
+	PasswordHash string `json:"password_hash"`
+	Role         string `json:"role"`
+}
+
+// AuthToken é um token estático de API; apenas o SHA-256 do token é guardado.
+type AuthToken struct {
+	Name        string `json:"name"`
+	TokenSHA256 string `json:"token_sha256"`
+	Role        string `json:"role"`
+}
+
+type authConfig struct {
+	Users  []AuthUser  `json:"users"`
+	Tokens []AuthToken `json:"tokens"`
+}
+
+// Principal identifica quem fez a requisição.
+type Principal struct {
+	Name string
+	Role string
+	// csrf é vazio para tokens de API, que não dependem de cookies.
+	csrf string
+}
+
+type session struct {
+	principal Principal
+	expires   time.Time
+}
+
+// AuthStore guarda usuários e tokens (config/auth.json) e as sessões em memória.
+type AuthStore struct {
+	mu       sync.Mutex
+	path     string
+	config   authConfig
+	sessions map[string]*session
+}
+
+var auth *AuthStore
+
+func newAuthStore(path string) *AuthStore {
+	return &AuthStore{path: path, sessions: make(map[string]*session)}
+}
+
+func validRole(role string) bool {
+	return role == RoleViewer || role == RoleOperator
+}
+
+// Load lê a configuração. Na primeira execução cria o usuário "admin" com uma
+// senha aleatória, exibida uma única vez no log.
+func (a *AuthStore) Load() error {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	err := readJSONFile(a.path, &a.config)
+	if err == nil {
+		return nil
+	}
+	if !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+	password := randomToken(12)
+	hash, err := hashPassword(password)
+	if err != nil {
+		return err
+	}
+	a.config = authConfig{Users: []AuthUser{{Username: "admin", PasswordHash: hash, Role: RoleOperator}}}
+	if err := saveAuthConfig(a.path, a.config); err != nil {
+		return err
+	}
+	log.Printf("Usuário inicial criado: admin / %s (altere com \"go-sync-tool useradd admin operator\")", password)
+	return nil
+}
+
+// saveAuthConfig grava a configuração legível apenas pelo dono, pois contém
+// hashes. O conteúdo vai para um arquivo temporário, que os.CreateTemp já cria
+// com permissão 0600, e que então substitui o anterior.
+func saveAuthConfig(path string, cfg authConfig) error {
+	file, err := os.CreateTemp(filepath.Dir(path), ".auth-*.tmp")
+	if err != nil {
+		return err
+	}
+	err = json.NewEncoder(file).Encode(cfg)
+	if cerr := file.Close(); err == nil {
+		err = cerr
+	}
+	if err == nil {
+		err = os.Rename(file.Name(), path)
+	}
+	if err != nil {
+		os.Remove(file.Name())
+	}
+	return err
+}
+
+// Login valida usuário e senha e abre uma sessão, retornando seu ID.
+func (a *AuthStore) Login(username, password string) (string, bool) {
+	a.mu.Lock()
+	var user *AuthUser
+	for i := range a.config.Users {
+		if a.config.Users[i].Username == username {
+			user = &a.config.Users[i]
+			break
+		}
+	}
+	hash := dummyPasswordHash
+	if user != nil {
+		hash = user.PasswordHash
+	}
+	a.mu.Unlock()
+
+	// O hash é verificado mesmo para usuários inexistentes, para não revelar quais existem pelo tempo de resposta.
+	if !checkPassword(hash, password) || user == nil {
+		return "", false
+	}
+
+	id := randomToken(32)
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	now := time.Now()
+	for key, s := range a.sessions {
+		if now.After(s.expires) {
+			delete(a.sessions, key)
+		}
+	}
+	a.sessions[id] = &session{
+		principal: Principal{Name: user.Username, Role: user.Role, csrf: randomToken(32)},
+		expires:   now.Add(sessionLifetime),
+	}
+	return id, true
+}
+
+func (a *AuthStore) Logout(id string) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	delete(a.sessions, id)
+}
+
+// Authenticate identifica a requisição pelo token de API (Authorization: Bearer)
+// ou pelo cookie de sessão.
+func (a *AuthStore) Authenticate(r *http.Request) (Principal, bool) {
+	if header := r.Header.Get("Authorization"); header != "" {
+		token, ok := strings.CutPrefix(header, "Bearer ")
+		if !ok {
+			return Principal{}, false
+		}
+		sum := sha256.Sum256([]byte(token))
+		digest := hex.EncodeToString(sum[:])
+		a.mu.Lock()
+		defer a.mu.Unlock()
+		for _, t := range a.config.Tokens {
+			if subtle.ConstantTimeCompare([]byte(t.TokenSHA256), []byte(digest)) == 1 {
+				return Principal{Name: "token:" + t.Name, Role: t.Role}, true
+			}
+		}
+		return Principal{}, false
+	}
+
+	cookie, err := r.Cookie(sessionCookie)
+	if err != nil {
+		return Principal{}, false
+	}
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	s, ok := a.sessions[cookie.Value]
+	if !ok {
+		return Principal{}, false
+	}
+	if time.Now().After(s.expires) {
+		delete(a.sessions, cookie.Value)
+		return Principal{}, false
+	}
+	return s.principal, true
+}
+
+//================================================================//
+// SENHAS E TOKENS
+//================================================================//
+
+// dummyPasswordHash é verificado quando o usuário não existe.
+var dummyPasswordHash, _ = hashPassword("usuario-inexistente")
+
+func randomToken(n int) string {
+	b := make([]byte, n)
+	rand.Read(b)
+	return base64.RawURLEncoding.EncodeToString(b)
+}
+
+// hashPassword gera o hash bcrypt da senha. Senhas com mais de 72 bytes, o
+// limite do bcrypt, são recusadas.
+func hashPassword(password string) (string, error) {
+	hash, err := bcrypt.GenerateFromPassword([]byte(password), bcryptCost)
+	if err != nil {
+		return "", err
+	}
+	return string(hash), nil
+}
+
+// checkPassword verifica a senha contra um hash bcrypt.
+func checkPassword(encoded, password string) bool {
+	return bcrypt.CompareHashAndPassword([]byte(encoded), []byte(password)) == nil
+}
+
+//================================================================//
+// MIDDLEWARE
+//================================================================//
+
+type principalKey struct{}
+
+func principalFrom(r *http.Request) Principal {
+	p, _ := r.Context().Value(principalKey{}).(Principal)
+	return p
+}
+
+// publicPaths não exigem autenticação, assim como os arquivos em /static/,
+// usados também pela página de login.
+var publicPaths = map[string]bool{"/login": true, "/api/v1/i18n": true}
+
+func isPublicPath(path string) bool {
+	return publicPaths[path] || strings.HasPrefix(path, "/static/")
+}
+
+// requireAuth autentica todas as requisições, exige o papel "operator" para
+// métodos que alteram estado e o cabeçalho CSRF para requisições com cookie.
+func requireAuth(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if isPublicPath(r.URL.Path) {
+			next.ServeHTTP(w, r)
+			return
+		}
+		p, ok := auth.Authenticate(r)
+		if !ok {
+			if r.Method == http.MethodGet && (r.URL.Path == "/" || r.URL.Path == "/viewer") {
+				http.Redirect(w, r, "/login", http.StatusSeeOther)
+				return
+			}
+			writeError(w, apiError(http.StatusUnauthorized, ErrCodeUnauthorized, "Autenticação necessária."))
+			return
+		}
+		if !safeMethod(r.Method) {
+			if p.csrf != "" && subtle.ConstantTimeCompare([]byte(r.Header.Get(csrfHeader)), []byte(p.csrf)) != 1 {
+				writeError(w, apiError(http.StatusForbidden, ErrCodeCSRF, "Token CSRF ausente ou inválido."))
+				return
+			}
+			if p.Role != RoleOperator && r.URL.Path != "/logout" {
+				writeError(w, apiError(http.StatusForbidden, ErrCodeForbidden, "Permissão negada: acesso somente leitura."))
+				return
+			}
+		}
+		next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), principalKey{}, p)))
+	})
+}
+
+func safeMethod(method string) bool {
+	return method == http.MethodGet || method == http.MethodHead || method == http.MethodOptions
+}
+
+// checkSameOrigin aceita o upgrade do WebSocket apenas de páginas servidas por este mesmo host.
+func checkSameOrigin(r *http.Request) bool {
+	origin := r.Header.Get("Origin")
+	if origin == "" {
+		// Clientes que não são navegadores não enviam Origin e já passaram pela autenticação.
+		return true
+	}
+	u, err := url.Parse(origin)
+	return err == nil && strings.EqualFold(u.Host, r.Host)
+}
+
+//================================================================//
+// HTTP
+//================================================================//
+
+// handleLogin exibe o formulário (GET) ou valida as credenciais e abre a sessão (POST).
+func handleLogin(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		serveWebAsset(w, r, "login.html")
+	case http.MethodPost:
+		if !checkSameOrigin(r) {
+			writeError(w, apiError(http.StatusForbidden, ErrCodeForbidden, "Origem não permitida."))
+			return
+		}
+		username := r.FormValue("username")
+		keys := loginKeys(r, username)
+		if wait := logins.blocked(keys, time.Now()); wait > 0 {
+			log.Printf("Login bloqueado para o usuário %q a partir de %s por %s", username, r.RemoteAddr, wait.Round(time.Second))
+			w.Header().Set("Retry-After", strconv.Itoa(int(wait.Seconds()+1)))
+			http.Redirect(w, r, "/login?erro=limite", http.StatusSeeOther)
+			return
+		}
+		select {
+		case loginSlots <- struct{}{}:
+		case <-r.Context().Done():
+			return
+		}
+		id, ok := auth.Login(username, r.FormValue("password"))
+		<-loginSlots
+		if !ok {
+			logins.fail(keys, time.Now())
+			log.Printf("Falha de login para o usuário %q a partir de %s", username, r.RemoteAddr)
+			http.Redirect(w, r, "/login?erro=1", http.StatusSeeOther)
+			return
+		}
+		logins.reset(keys)
+		http.SetCookie(w, &http.Cookie{
+			Name:     sessionCookie,
+			Value:    id,
+			Path:     "/",
+			MaxAge:   int(sessionLifetime.Seconds()),
+			HttpOnly: true,
+			Secure:   r.TLS != nil,
+			SameSite: http.SameSiteStrictMode,
+		})
+		http.Redirect(w, r, "/", http.StatusSeeOther)
+	}
+}
+
+// handleLogout encerra a sessão atual.
+func handleLogout(w http.ResponseWriter, r *http.Request) {
+	if cookie, err := r.Cookie(sessionCookie); err == nil {
+		auth.Logout(cookie.Value)
+	}
+	http.SetCookie(w, &http.Cookie{Name: sessionCookie, Value: "", Path: "/", MaxAge: -1, HttpOnly: true})
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// handleAuthMe informa o usuário atual, seu papel e o token CSRF da sessão.
+func handleAuthMe(w http.ResponseWriter, r *http.Request) {
+	p := principalFrom(r)
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]string{"username": p.Name, "role": p.Role, "csrf_token": p.csrf})
+}
+
+//================================================================//
+// LIMITE DE TENTATIVAS DE LOGIN
+//================================================================//
+
+const (
+	// loginFreeAttempts falhas seguidas são permitidas antes do bloqueio, que
+	// começa em 1s e dobra a cada nova falha até loginMaxBackoff.
+	loginFreeAttempts = 5
+	loginMaxBackoff   = 15 * time.Minute
+	// loginConcurrency limita quantas senhas são verificadas ao mesmo tempo,
+	// já que cada verificação bcrypt custa dezenas de milissegundos de CPU.
+	loginConcurrency = 4
+)
+
+var (
+	logins     = newLoginLimiter()
+	loginSlots = make(chan struct{}, loginConcurrency)
+)
+
+// loginLimiter conta falhas de login por chave (endereço de origem e usuário)
+// e bloqueia novas tentativas com espera exponencial.
+type loginLimiter struct {
+	mu        sync.Mutex
+	failures  map[string]*loginFailures
+	lastSweep time.Time
+}
+
+type loginFailures struct {
+	count int
+	last  time.Time
+}
+
+func newLoginLimiter() *loginLimiter {
+	return &loginLimiter{failures: make(map[string]*loginFailures)}
+}
+
+// loginKeys retorna as chaves de limite de uma tentativa: o IP de origem e o usuário.
+func loginKeys(r *http.Request, username string) []string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		host = r.RemoteAddr
+	}
+	return []string{"ip:" + host, "user:" + strings.ToLower(username)}
+}
+
+// loginBackoff é o tempo de bloqueio após count falhas seguidas.
+func loginBackoff(count int) time.Duration {
+	if count < loginFreeAttempts {
+		return 0
+	}
+	shift := count - loginFreeAttempts
+	if shift >= 10 {
+		return loginMaxBackoff
+	}
+	return min(time.Second<<shift, loginMaxBackoff)
+}
+
+// blocked retorna quanto falta para que alguma das chaves volte a poder tentar.
+func (l *loginLimiter) blocked(keys []string, now time.Time) time.Duration {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	var wait time.Duration
+	for _, key := range keys {
+		if f := l.failures[key]; f != nil {
+			wait = max(wait, f.last.Add(loginBackoff(f.count)).Sub(now))
+		}
+	}
+	return wait
+}
+
+// fail registra uma falha para cada chave. Entradas sem falhas recentes são
+// descartadas, no máximo uma vez por minuto, para o mapa não crescer sem limite.
+func (l *loginLimiter) fail(keys []string, now time.Time) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if now.Sub(l.lastSweep) > time.Minute {
+		for key, f := range l.failures {
+			if now.Sub(f.last) > loginMaxBackoff {
+				delete(l.failures, key)
+			}
+		}
+		l.lastSweep = now
+	}
+	for _, key := range keys {
+		f := l.failures[key]
+		if f == nil {
+			f = &loginFailures{}
+			l.failures[key] = f
+		}
+		f.count++
+		f.last = now
+	}
+}
+
+// reset esquece as falhas das chaves após um login bem-sucedido.
+func (l *loginLimiter) reset(keys []string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	for _, key := range keys {
+		delete(l.failures, key)
+	}
+}
+
+//================================================================//
+// LINHA DE COMANDO
+//================================================================//
+
+// runAuthCommand trata os subcomandos de gerenciamento de acesso:
+//
+//	go-sync-tool useradd <usuário> <viewer|operator>   (lê a senha da entrada padrão)
+//	go-sync-tool tokenadd <nome> <viewer|operator>     (exibe o token gerado)
+//
+// Retorna false se args não for um desses comandos. O servidor precisa ser
+// reiniciado para carregar as alterações.
+func runAuthCommand(args []string) bool {
+	if len(args) == 0 || (args[0] != "useradd" && args[0] != "tokenadd") {
+		return false
+	}
+	if len(args) != 3 || !validRole(args[2]) {
+		log.Fatalf("Uso: %s %s <nome> <viewer|operator>", filepath.Base(os.Args[0]), args[0])
+	}
+	name, role := args[1], args[2]
+	path := filepath.Join(configDir, "auth.json")
+	var cfg authConfig
+	if err := readJSONFile(path, &cfg); err != nil && !errors.Is(err, os.ErrNotExist) {
+		log.Fatalf("Falha ao ler %s: %v", path, err)
+	}
+
+	switch args[0] {
+	case "useradd":
+		fmt.Fprint(os.Stderr, "Senha: ")
+		line, err := bufio.NewReader(os.Stdin).ReadString('\n')
+		password := strings.TrimRight(line, "\r\n")
+		if password == "" {
+			log.Fatalf("Senha vazia: %v", err)
+		}
+		hash, err := hashPassword(password)
+		if err != nil {
+			log.Fatalf("Falha ao gerar hash da senha: %v", err)
+		}
+		user := AuthUser{Username: name, PasswordHash: hash, Role: role}
+		replaced := false
+		for i := range cfg.Users {
+			if cfg.Users[i].Username == name {
+				cfg.Users[i], replaced = user, true
+			}
+		}
+		if !replaced {
+			cfg.Users = append(cfg.Users, user)
+		}
+	case "tokenadd":
+		token := "gst_" + randomToken(32)
+		sum := sha256.Sum256([]byte(token))
+		cfg.Tokens = append(cfg.Tokens, AuthToken{Name: name, TokenSHA256: hex.EncodeToString(sum[:]), Role: role})
+		fmt.Println(token)
+	}
+
+	os.MkdirAll(configDir, os.ModePerm)
+	if err := saveAuthConfig(path, cfg); err != nil {
+		log.Fatalf("Falha ao salvar %s: %v", path, err)
+	}
+	log.Printf("%s salvo; reinicie o servidor para aplicar.", path)
+	return true
+}
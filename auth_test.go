@@ -0,0 +1,52 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestLoginBackoff(t *testing.T) {
+	tests := []struct {
+		count int
+		want  time.Duration
+	}{
+		{0, 0},
+		{loginFreeAttempts - 1, 0},
+		{loginFreeAttempts, time.Second},
+		{loginFreeAttempts + 1, 2 * time.Second},
+		{loginFreeAttempts + 9, 512 * time.Second},
+		{loginFreeAttempts + 10, loginMaxBackoff},
+		{loginFreeAttempts + 100, loginMaxBackoff},
+	}
+	for _, tt := range tests {
+		if got := loginBackoff(tt.count); got != tt.want {
+			t.Errorf("loginBackoff(%d) = %v, esperado %v", tt.count, got, tt.want)
+		}
+	}
+}
+
+func TestLoginLimiter(t *testing.T) {
+	now := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	l := newLoginLimiter()
+	keys := []string{"ip:10.0.0.1", "user:op"}
+	for range loginFreeAttempts {
+		if wait := l.blocked(keys, now); wait != 0 {
+			t.Fatalf("bloqueado antes de %d falhas: %v", loginFreeAttempts, wait)
+		}
+		l.fail(keys, now)
+	}
+	if wait := l.blocked(keys, now); wait != time.Second {
+		t.Errorf("espera após %d falhas = %v, esperado 1s", loginFreeAttempts, wait)
+	}
+	// Outro endereço tentando o mesmo usuário também é bloqueado.
+	if wait := l.blocked([]string{"ip:10.0.0.2", "user:op"}, now); wait != time.Second {
+		t.Errorf("espera pelo usuário = %v, esperado 1s", wait)
+	}
+	if wait := l.blocked(keys, now.Add(time.Second)); wait != 0 {
+		t.Errorf("ainda bloqueado após o intervalo: %v", wait)
+	}
+	l.reset(keys)
+	if wait := l.blocked(keys, now); wait != 0 {
+		t.Errorf("bloqueado após reset: %v", wait)
+	}
+}
@@ -0,0 +1,118 @@
+package main
+
+import (
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+//================================================================//
+// NAVEGAÇÃO DE DIRETÓRIOS
+//================================================================//
+
+// maxListEntries limita os subdiretórios retornados por /fs/list.
+const maxListEntries = 1000
+
+// DirEntry descreve um subdiretório. Files, Dirs e Size consideram apenas o
+// conteúdo imediato (não recursivo), para que a listagem continue rápida.
+type DirEntry struct {
+	Name       string    `json:"name"`
+	Path       string    `json:"path"`
+	Files      int       `json:"files"`
+	Dirs       int       `json:"dirs"`
+	Size       int64     `json:"size"`
+	ModifiedAt time.Time `json:"modified_at"`
+	Error      string    `json:"error,omitempty"` // conteúdo ilegível (ex.: sem permissão)
+}
+
+// DirListing é a resposta de /fs/list. Sem path, Entries são os diretórios permitidos.
+type DirListing struct {
+	Path      string     `json:"path,omitempty"`
+	Parent    string     `json:"parent,omitempty"` // vazio na raiz de um diretório permitido
+	Entries   []DirEntry `json:"entries"`
+	Truncated bool       `json:"truncated,omitempty"`
+}
+
+// handleListDir lista os subdiretórios de ?path=, que deve estar dentro dos
+// diretórios permitidos. Links simbólicos que apontam para fora deles são omitidos.
+func handleListDir(w http.ResponseWriter, r *http.Request) {
+	path := r.URL.Query().Get("path")
+	if path == "" {
+		listing := DirListing{Entries: []DirEntry{}}
+		for _, root := range sandbox.Roots() {
+			if entry, ok := describeDir(root, root); ok {
+				listing.Entries = append(listing.Entries, entry)
+			}
+		}
+		writeJSON(w, http.StatusOK, listing)
+		return
+	}
+
+	dir, err := validateDirectory("path", path)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		writeError(w, validationError("path", "não foi possível ler %s: %v", path, err))
+		return
+	}
+
+	listing := DirListing{Path: dir, Entries: []DirEntry{}}
+	if parent := filepath.Dir(dir); parent != dir {
+		if _, err := sandbox.Resolve(parent); err == nil {
+			listing.Parent = parent
+		}
+	}
+	for _, e := range entries {
+		if !e.IsDir() && e.Type()&os.ModeSymlink == 0 {
+			continue
+		}
+		if len(listing.Entries) == maxListEntries {
+			listing.Truncated = true
+			break
+		}
+		child := filepath.Join(dir, e.Name())
+		if e.Type()&os.ModeSymlink != 0 {
+			if _, err := sandbox.Resolve(child); err != nil {
+				continue
+			}
+		}
+		if entry, ok := describeDir(e.Name(), child); ok {
+			listing.Entries = append(listing.Entries, entry)
+		}
+	}
+	sort.Slice(listing.Entries, func(i, j int) bool {
+		return strings.ToLower(listing.Entries[i].Name) < strings.ToLower(listing.Entries[j].Name)
+	})
+	writeJSON(w, http.StatusOK, listing)
+}
+
+// describeDir conta o conteúdo imediato de path. Retorna false se path não for um diretório.
+func describeDir(name, path string) (DirEntry, bool) {
+	info, err := os.Stat(path)
+	if err != nil || !info.IsDir() {
+		return DirEntry{}, false
+	}
+	entry := DirEntry{Name: name, Path: path, ModifiedAt: info.ModTime()}
+	children, err := os.ReadDir(path)
+	if err != nil {
+		entry.Error = err.Error()
+		return entry, true
+	}
+	for _, c := range children {
+		if c.IsDir() {
+			entry.Dirs++
+			continue
+		}
+		entry.Files++
+		if ci, err := c.Info(); err == nil && ci.Mode().IsRegular() {
+			entry.Size += ci.Size()
+		}
+	}
+	return entry, true
+}
@@ -0,0 +1,121 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestHandleListDir(t *testing.T) {
+	base, err := filepath.EvalSymlinks(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	root := filepath.Join(base, "raiz")
+	for _, dir := range []string{"raiz/Beta/interno", "raiz/alfa", "fora"} {
+		if err := os.MkdirAll(filepath.Join(base, dir), 0o755); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for _, name := range []string{"raiz/arquivo", "raiz/Beta/x", "raiz/Beta/y"} {
+		if err := os.WriteFile(filepath.Join(base, name), []byte("12345"), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	links := true
+	for _, l := range [][2]string{{"raiz/atalho", "alfa"}, {"raiz/saida", filepath.Join(base, "fora")}} {
+		if err := os.Symlink(l[1], filepath.Join(base, l[0])); err != nil {
+			links = false
+			break
+		}
+	}
+	saved := sandbox
+	sandbox = &PathSandbox{roots: []string{root}}
+	t.Cleanup(func() { sandbox = saved })
+
+	list := func(path string) (*httptest.ResponseRecorder, DirListing) {
+		w := httptest.NewRecorder()
+		handleListDir(w, httptest.NewRequest(http.MethodGet, "/api/v1/fs/list?path="+url.QueryEscape(path), nil))
+		var listing DirListing
+		if w.Code == http.StatusOK {
+			if err := json.NewDecoder(w.Body).Decode(&listing); err != nil {
+				t.Fatal(err)
+			}
+		}
+		return w, listing
+	}
+
+	t.Run("raízes", func(t *testing.T) {
+		_, listing := list("")
+		if len(listing.Entries) != 1 || listing.Entries[0].Path != root {
+			t.Errorf("entradas = %+v, esperado apenas %s", listing.Entries, root)
+		}
+	})
+
+	t.Run("raiz", func(t *testing.T) {
+		w, listing := list(root)
+		if w.Code != http.StatusOK {
+			t.Fatalf("status %d: %s", w.Code, w.Body)
+		}
+		if listing.Parent != "" {
+			t.Errorf("Parent = %q, esperado vazio na raiz permitida", listing.Parent)
+		}
+		var names []string
+		for _, e := range listing.Entries {
+			names = append(names, e.Name)
+		}
+		// O link para fora da raiz é omitido; a ordem ignora maiúsculas.
+		want := []string{"alfa", "Beta"}
+		if links {
+			want = []string{"alfa", "atalho", "Beta"}
+		}
+		if !reflect.DeepEqual(names, want) {
+			t.Errorf("entradas = %q, esperado %q", names, want)
+		}
+		for _, e := range listing.Entries {
+			if e.Name == "Beta" && (e.Files != 2 || e.Dirs != 1 || e.Size != 10) {
+				t.Errorf("Beta = %+v, esperado 2 arquivos, 1 diretório e 10 bytes", e)
+			}
+		}
+	})
+
+	t.Run("subdiretório", func(t *testing.T) {
+		_, listing := list(filepath.Join(root, "Beta"))
+		if listing.Parent != root || len(listing.Entries) != 1 || listing.Entries[0].Name != "interno" {
+			t.Errorf("listagem = %+v, esperado interno, com a raiz como pai", listing)
+		}
+	})
+
+	errorTests := []struct {
+		name, path string
+		status     int
+		code       string
+	}{
+		{"fora dos diretórios permitidos", filepath.Join(base, "fora"), http.StatusForbidden, ErrCodePathForbidden},
+		{"escapando com ..", filepath.Join(root, "..", "fora"), http.StatusForbidden, ErrCodePathForbidden},
+		{"link para fora", filepath.Join(root, "saida"), http.StatusForbidden, ErrCodePathForbidden},
+		{"arquivo", filepath.Join(root, "arquivo"), http.StatusBadRequest, ErrCodeValidation},
+		{"inexistente", filepath.Join(root, "nada"), http.StatusBadRequest, ErrCodeValidation},
+	}
+	for _, tt := range errorTests {
+		t.Run(tt.name, func(t *testing.T) {
+			if !links && tt.name == "link para fora" {
+				t.Skip("links simbólicos indisponíveis")
+			}
+			w, _ := list(tt.path)
+			var body APIError
+			json.NewDecoder(w.Body).Decode(&body)
+			if w.Code != tt.status || body.Code != tt.code {
+				t.Errorf("status %d, código %q; esperado %d, %q", w.Code, body.Code, tt.status, tt.code)
+			}
+			if tt.code == ErrCodeValidation && body.Field != "path" {
+				t.Errorf("campo = %q, esperado path", body.Field)
+			}
+		})
+	}
+}
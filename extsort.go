@@ -0,0 +1,236 @@
+package main
+
+import (
+	"bufio"
+	"container/heap"
+	"context"
+	"encoding/json"
+	"fmt"
+	"iter"
+	"os"
+	"sort"
+)
+
+//================================================================//
+// ORDENAÇÃO EXTERNA DAS ENTRADAS
+//================================================================//
+
+// Os relatórios de coleta são gravados em ordem de Path, o que permite
+// comparar dois relatórios lendo ambos em paralelo, com memória constante. A
+// ordenação usa no máximo sortChunkSize entradas em memória: cada bloco cheio
+// é ordenado e gravado num arquivo temporário, e os blocos são intercalados
+// no final.
+
+// sortChunkSize é o número de entradas ordenadas em memória antes de gravar um bloco.
+const sortChunkSize = 100_000
+
+// metadataSorter ordena entradas por Path, gravando blocos em dir se necessário.
+type metadataSorter struct {
+	dir       string
+	chunkSize int
+	chunk     []FileMetadata
+	spills    []string
+}
+
+func newMetadataSorter(dir string) *metadataSorter {
+	return &metadataSorter{dir: dir, chunkSize: sortChunkSize}
+}
+
+func (s *metadataSorter) Add(f FileMetadata) error {
+	s.chunk = append(s.chunk, f)
+	if len(s.chunk) >= s.chunkSize {
+		return s.spill()
+	}
+	return nil
+}
+
+// spill grava o bloco atual, já ordenado, num arquivo temporário (.tmp, que
+// a listagem e a limpeza dos relatórios ignoram).
+func (s *metadataSorter) spill() error {
+	sortByPath(s.chunk)
+	file, err := os.CreateTemp(s.dir, "sort-*.tmp")
+	if err != nil {
+		return err
+	}
+	s.spills = append(s.spills, file.Name())
+	buf := bufio.NewWriterSize(file, reportBufferSize)
+	enc := json.NewEncoder(buf)
+	for _, f := range s.chunk {
+		if err := enc.Encode(f); err != nil {
+			file.Close()
+			return err
+		}
+	}
+	if err := buf.Flush(); err != nil {
+		file.Close()
+		return err
+	}
+	s.chunk = s.chunk[:0]
+	return file.Close()
+}
+
+// Sorted percorre todas as entradas adicionadas, em ordem de Path.
+func (s *metadataSorter) Sorted() iter.Seq2[FileMetadata, error] {
+	sortByPath(s.chunk)
+	if len(s.spills) == 0 {
+		return sliceSeq(s.chunk)
+	}
+	seqs := []iter.Seq2[FileMetadata, error]{sliceSeq(s.chunk)}
+	for _, name := range s.spills {
+		seqs = append(seqs, spillSeq(name))
+	}
+	return mergeSorted(seqs)
+}
+
+// Close remove os arquivos temporários.
+func (s *metadataSorter) Close() {
+	for _, name := range s.spills {
+		os.Remove(name)
+	}
+	s.spills, s.chunk = nil, nil
+}
+
+func sortByPath(files []FileMetadata) {
+	sort.Slice(files, func(i, j int) bool { return files[i].Path < files[j].Path })
+}
+
+func sliceSeq(files []FileMetadata) iter.Seq2[FileMetadata, error] {
+	return func(yield func(FileMetadata, error) bool) {
+		for _, f := range files {
+			if !yield(f, nil) {
+				return
+			}
+		}
+	}
+}
+
+// spillSeq lê um bloco gravado por spill.
+func spillSeq(name string) iter.Seq2[FileMetadata, error] {
+	return func(yield func(FileMetadata, error) bool) {
+		file, err := os.Open(name)
+		if err != nil {
+			yield(FileMetadata{}, err)
+			return
+		}
+		defer file.Close()
+		dec := json.NewDecoder(bufio.NewReaderSize(file, reportBufferSize))
+		for dec.More() {
+			var f FileMetadata
+			if err := dec.Decode(&f); err != nil {
+				yield(FileMetadata{}, err)
+				return
+			}
+			if !yield(f, nil) {
+				return
+			}
+		}
+	}
+}
+
+// mergeCursor é a próxima entrada de uma das sequências intercaladas.
+type mergeCursor struct {
+	file FileMetadata
+	next func() (FileMetadata, error, bool)
+	stop func()
+}
+
+type mergeHeap []*mergeCursor
+
+func (h mergeHeap) Len() int           { return len(h) }
+func (h mergeHeap) Less(i, j int) bool { return h[i].file.Path < h[j].file.Path }
+func (h mergeHeap) Swap(i, j int)      { h[i], h[j] = h[j], h[i] }
+func (h *mergeHeap) Push(x any)        { *h = append(*h, x.(*mergeCursor)) }
+func (h *mergeHeap) Pop() any {
+	old := *h
+	c := old[len(old)-1]
+	*h = old[:len(old)-1]
+	return c
+}
+
+// mergeSorted intercala sequências já ordenadas por Path.
+func mergeSorted(seqs []iter.Seq2[FileMetadata, error]) iter.Seq2[FileMetadata, error] {
+	return func(yield func(FileMetadata, error) bool) {
+		h := make(mergeHeap, 0, len(seqs))
+		defer func() {
+			for _, c := range h {
+				c.stop()
+			}
+		}()
+		for _, seq := range seqs {
+			next, stop := iter.Pull2(seq)
+			f, err, ok := next()
+			if err != nil {
+				stop()
+				yield(FileMetadata{}, err)
+				return
+			}
+			if !ok {
+				stop()
+				continue
+			}
+			h = append(h, &mergeCursor{file: f, next: next, stop: stop})
+		}
+		heap.Init(&h)
+		for h.Len() > 0 {
+			c := h[0]
+			if !yield(c.file, nil) {
+				return
+			}
+			f, err, ok := c.next()
+			switch {
+			case err != nil:
+				yield(FileMetadata{}, err)
+				return
+			case ok:
+				c.file = f
+				heap.Fix(&h, 0)
+			default:
+				c.stop()
+				heap.Pop(&h)
+			}
+		}
+	}
+}
+
+// pullSorted converte seq num iterador de chamada, que falha se as entradas
+// não estiverem em ordem estritamente crescente de Path.
+func pullSorted(seq iter.Seq2[FileMetadata, error]) (func() (FileMetadata, bool, error), func()) {
+	next, stop := iter.Pull2(seq)
+	var last string
+	started := false
+	return func() (FileMetadata, bool, error) {
+		f, err, ok := next()
+		if err != nil || !ok {
+			return FileMetadata{}, false, err
+		}
+		if started && f.Path <= last {
+			return FileMetadata{}, false, fmt.Errorf("entradas fora de ordem: %q depois de %q", f.Path, last)
+		}
+		started, last = true, f.Path
+		return f, true, nil
+	}, stop
+}
+
+// sortedFiles retorna as entradas do relatório em ordem de Path. Relatórios
+// gravados já ordenados são lidos diretamente; os demais (como os do formato
+// antigo) passam antes pela ordenação externa. A função retornada remove os
+// arquivos temporários.
+func sortedFiles(ctx context.Context, r *collectionReader) (iter.Seq2[FileMetadata, error], func(), error) {
+	if r.Header.Sorted {
+		return r.Files(), func() {}, nil
+	}
+	sorter := newMetadataSorter(collectedDir)
+	for f, err := range r.Files() {
+		if err == nil {
+			err = checkPauseAndCancel(ctx)
+		}
+		if err == nil {
+			err = sorter.Add(f)
+		}
+		if err != nil {
+			sorter.Close()
+			return nil, nil, err
+		}
+	}
+	return sorter.Sorted(), sorter.Close, nil
+}
@@ -0,0 +1,155 @@
+package main
+
+import (
+	"errors"
+	"iter"
+	"os"
+	"reflect"
+	"testing"
+)
+
+// collectPaths percorre seq e retorna os caminhos, parando no primeiro erro.
+func collectPaths(seq iter.Seq2[FileMetadata, error]) ([]string, error) {
+	var paths []string
+	for f, err := range seq {
+		if err != nil {
+			return paths, err
+		}
+		paths = append(paths, f.Path)
+	}
+	return paths, nil
+}
+
+func metadataOf(paths ...string) []FileMetadata {
+	files := make([]FileMetadata, len(paths))
+	for i, p := range paths {
+		files[i] = FileMetadata{Path: p}
+	}
+	return files
+}
+
+func TestMetadataSorter(t *testing.T) {
+	input := []string{"m", "b", "z", "a/b", "a", "k", "c", "a/a", "y"}
+	want := []string{"a", "a/a", "a/b", "b", "c", "k", "m", "y", "z"}
+	tests := []struct {
+		name      string
+		chunkSize int
+		spills    int
+	}{
+		{"sem blocos gravados", sortChunkSize, 0},
+		{"blocos de 2", 2, 4},
+		{"blocos de 3", 3, 3},
+		{"uma entrada por bloco", 1, 9},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			s := newMetadataSorter(dir)
+			s.chunkSize = tt.chunkSize
+			for _, f := range metadataOf(input...) {
+				if err := s.Add(f); err != nil {
+					t.Fatal(err)
+				}
+			}
+			if len(s.spills) != tt.spills {
+				t.Errorf("%d blocos gravados, esperado %d", len(s.spills), tt.spills)
+			}
+			got, err := collectPaths(s.Sorted())
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(got, want) {
+				t.Errorf("Sorted = %q, esperado %q", got, want)
+			}
+			s.Close()
+			if entries, _ := os.ReadDir(dir); len(entries) != 0 {
+				t.Errorf("arquivos temporários não removidos: %v", entries)
+			}
+		})
+	}
+}
+
+func TestMergeSorted(t *testing.T) {
+	errRead := errors.New("falha de leitura")
+	failing := func(yield func(FileMetadata, error) bool) {
+		if yield(FileMetadata{Path: "b"}, nil) {
+			yield(FileMetadata{}, errRead)
+		}
+	}
+	tests := []struct {
+		name    string
+		seqs    []iter.Seq2[FileMetadata, error]
+		want    []string
+		wantErr error
+	}{
+		{"nenhuma sequência", nil, nil, nil},
+		{"sequências vazias", []iter.Seq2[FileMetadata, error]{sliceSeq(nil), sliceSeq(nil)}, nil, nil},
+		{
+			"intercaladas",
+			[]iter.Seq2[FileMetadata, error]{
+				sliceSeq(metadataOf("a", "d", "g")),
+				sliceSeq(nil),
+				sliceSeq(metadataOf("b", "e")),
+				sliceSeq(metadataOf("c", "f", "h", "i")),
+			},
+			[]string{"a", "b", "c", "d", "e", "f", "g", "h", "i"},
+			nil,
+		},
+		{
+			"erro numa das sequências",
+			[]iter.Seq2[FileMetadata, error]{sliceSeq(metadataOf("a", "c")), failing},
+			[]string{"a", "b"},
+			errRead,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := collectPaths(mergeSorted(tt.seqs))
+			if !errors.Is(err, tt.wantErr) {
+				t.Errorf("erro = %v, esperado %v", err, tt.wantErr)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("mergeSorted = %q, esperado %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestPullSorted(t *testing.T) {
+	tests := []struct {
+		name  string
+		paths []string
+		ok    bool
+	}{
+		{"vazio", nil, true},
+		{"crescente", []string{"a", "a/b", "b"}, true},
+		{"fora de ordem", []string{"a", "c", "b"}, false},
+		{"repetido", []string{"a", "b", "b"}, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			next, stop := pullSorted(sliceSeq(metadataOf(tt.paths...)))
+			defer stop()
+			var got []string
+			for {
+				f, ok, err := next()
+				if err != nil {
+					if tt.ok {
+						t.Fatalf("erro inesperado: %v", err)
+					}
+					return
+				}
+				if !ok {
+					break
+				}
+				got = append(got, f.Path)
+			}
+			if !tt.ok {
+				t.Fatalf("entradas %q aceitas, esperado erro", got)
+			}
+			if !reflect.DeepEqual(got, tt.paths) {
+				t.Errorf("entradas = %q, esperado %q", got, tt.paths)
+			}
+		})
+	}
+}
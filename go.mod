@@ -3,3 +3,5 @@ module go-sync-tool
 go 1.24.6
 
 require github.com/gorilla/websocket v1.5.3
+
+require golang.org/x/crypto v0.48.0
@@ -0,0 +1,178 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+)
+
+//================================================================//
+// INTERNACIONALIZAÇÃO
+//================================================================//
+
+// Os catálogos ficam em web/i18n/<idioma>.json e são usados tanto pelo
+// servidor quanto pela interface. As mensagens usam parâmetros nomeados entre
+// chaves, ex.: "Copiando {count} arquivos de {source} para {dest}".
+
+const defaultLanguage = "pt-BR"
+
+// supportedLanguages lista os idiomas com catálogo, na ordem de preferência.
+var supportedLanguages = []string{"pt-BR", "en"}
+
+// langCookie guarda o idioma escolhido pelo usuário na interface.
+const langCookie = "lang"
+
+var catalogs = loadCatalogs()
+
+// serverLanguage é o idioma do texto gravado em "message" nos logs das
+// operações; clientes usam key e params para exibir no próprio idioma.
+var serverLanguage = defaultLanguage
+
+func loadCatalogs() map[string]map[string]string {
+	result := make(map[string]map[string]string)
+	for _, lang := range supportedLanguages {
+		var messages map[string]string
+		asset, ok := webAssets["i18n/"+lang+".json"]
+		if !ok {
+			log.Fatalf("Catálogo de mensagens ausente: %s", lang)
+		}
+		if err := json.Unmarshal(asset.content, &messages); err != nil {
+			log.Fatalf("Catálogo de mensagens inválido (%s): %v", lang, err)
+		}
+		result[lang] = messages
+	}
+	return result
+}
+
+// Text é uma mensagem traduzível: a chave do catálogo e seus parâmetros.
+// Um parâmetro também pode ser um Text, traduzido no mesmo idioma.
+type Text struct {
+	Key    string         `json:"key"`
+	Params map[string]any `json:"params,omitempty"`
+}
+
+// T monta um Text a partir de pares nome/valor, como em
+// T("log.copy_failed", "path", p, "error", err). Erros viram texto.
+func T(key string, args ...any) Text {
+	t := Text{Key: key}
+	if len(args) > 0 {
+		t.Params = make(map[string]any, len(args)/2)
+	}
+	for i := 0; i+1 < len(args); i += 2 {
+		name := fmt.Sprint(args[i])
+		switch v := args[i+1].(type) {
+		case Text, string, int, int64, float64:
+			t.Params[name] = v
+		case error:
+			t.Params[name] = v.Error()
+		default:
+			t.Params[name] = fmt.Sprint(v)
+		}
+	}
+	return t
+}
+
+// Render traduz o texto para lang, recorrendo ao idioma padrão e, por fim, à própria chave.
+func (t Text) Render(lang string) string {
+	if t.Key == "" {
+		return ""
+	}
+	msg, ok := catalogs[lang][t.Key]
+	if !ok {
+		if msg, ok = catalogs[defaultLanguage][t.Key]; !ok {
+			msg = t.Key
+		}
+	}
+	if len(t.Params) == 0 {
+		return msg
+	}
+	pairs := make([]string, 0, len(t.Params)*2)
+	for name, v := range t.Params {
+		value := fmt.Sprint(v)
+		if nested, ok := v.(Text); ok {
+			value = nested.Render(lang)
+		}
+		pairs = append(pairs, "{"+name+"}", value)
+	}
+	return strings.NewReplacer(pairs...).Replace(msg)
+}
+
+// String traduz o texto para o idioma do servidor.
+func (t Text) String() string {
+	return t.Render(serverLanguage)
+}
+
+// matchLanguage retorna o idioma suportado correspondente a tag ("en-US" → "en"), ou "".
+func matchLanguage(tag string) string {
+	tag = strings.TrimSpace(tag)
+	for _, lang := range supportedLanguages {
+		if strings.EqualFold(tag, lang) {
+			return lang
+		}
+	}
+	primary, _, _ := strings.Cut(tag, "-")
+	for _, lang := range supportedLanguages {
+		if p, _, _ := strings.Cut(lang, "-"); strings.EqualFold(primary, p) {
+			return lang
+		}
+	}
+	return ""
+}
+
+// negotiateLanguage escolhe o idioma da requisição: parâmetro ?lang=, cookie
+// de preferência do usuário, cabeçalho Accept-Language e, por fim, o do servidor.
+func negotiateLanguage(r *http.Request) string {
+	if lang := matchLanguage(r.URL.Query().Get("lang")); lang != "" {
+		return lang
+	}
+	if c, err := r.Cookie(langCookie); err == nil {
+		if lang := matchLanguage(c.Value); lang != "" {
+			return lang
+		}
+	}
+	return acceptedLanguage(r.Header.Values("Accept-Language"))
+}
+
+// acceptedLanguage escolhe, entre os idiomas suportados, o de maior peso nos
+// cabeçalhos Accept-Language; em caso de empate vale o citado primeiro.
+// Entradas com q=0 são recusas. "*" representa o idioma do servidor, que
+// também é a escolha quando nenhum idioma suportado é aceito.
+func acceptedLanguage(headers []string) string {
+	best, bestQ, anyQ := "", 0.0, 0.0
+	refused := make(map[string]bool)
+	for tag, q := range qualityValues(headers) {
+		if tag == "*" {
+			anyQ = max(anyQ, q)
+			continue
+		}
+		lang := matchLanguage(tag)
+		switch {
+		case lang == "":
+		case q == 0:
+			refused[lang] = true
+		case q > bestQ:
+			best, bestQ = lang, q
+		}
+	}
+	if best == "" || anyQ > bestQ && !refused[serverLanguage] {
+		return serverLanguage
+	}
+	return best
+}
+
+// Catalog é a resposta de /api/v1/i18n.
+type Catalog struct {
+	Language  string            `json:"language"`
+	Languages []string          `json:"languages"`
+	Messages  map[string]string `json:"messages"`
+}
+
+// handleI18n envia o catálogo do idioma negociado. É público, pois a página
+// de login também é traduzida.
+func handleI18n(w http.ResponseWriter, r *http.Request) {
+	lang := negotiateLanguage(r)
+	w.Header().Set("Vary", "Accept-Language, Cookie")
+	writeJSON(w, http.StatusOK, Catalog{Language: lang, Languages: supportedLanguages, Messages: catalogs[lang]})
+}
@@ -0,0 +1,27 @@
+package main
+
+import "testing"
+
+func TestAcceptedLanguage(t *testing.T) {
+	tests := []struct {
+		headers []string
+		want    string
+	}{
+		{nil, "pt-BR"},
+		{[]string{"en-US"}, "en"},
+		{[]string{"fr, en;q=0.5"}, "en"},
+		{[]string{"pt-BR;q=0.4, en;q=0.9"}, "en"},
+		{[]string{"en;q=0.4", "pt;q=0.9"}, "pt-BR"},
+		{[]string{"en;q=0.8, pt-BR;q=0.8"}, "en"}, // empate: vale o primeiro
+		{[]string{"en;q=0, fr"}, "pt-BR"},
+		{[]string{"EN-gb; Q=0.7, de"}, "en"},
+		{[]string{"en;q=abc, pt"}, "pt-BR"},
+		{[]string{"en;q=0.3, *;q=0.5"}, "pt-BR"},
+		{[]string{"en;q=0.3, pt-BR;q=0, *"}, "en"},
+	}
+	for _, tt := range tests {
+		if got := acceptedLanguage(tt.headers); got != tt.want {
+			t.Errorf("acceptedLanguage(%q) = %q, esperado %q", tt.headers, got, tt.want)
+		}
+	}
+}
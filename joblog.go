@@ -0,0 +1,314 @@
+package main
+
+import (
+	"bufio"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+)
+
+//================================================================//
+// LOGS ESTRUTURADOS POR OPERAÇÃO
+//================================================================//
+
+// Níveis de log, do menos ao mais grave.
+const (
+	LevelDebug = "debug"
+	LevelInfo  = "info"
+	LevelWarn  = "warn"
+	LevelError = "error"
+)
+
+// Códigos de erro registrados nos eventos, estáveis para filtros e scripts.
+const (
+	CodeStatFailed     = "stat_failed"
+	CodeHashFailed     = "hash_failed"
+	CodeCopyFailed     = "copy_failed"
+	CodeDeleteFailed   = "delete_failed"
+	CodeMetadataFailed = "metadata_failed"
+	CodeCompressFailed = "compress_failed"
+	CodeWatchFailed    = "watch_failed"
+	CodeJobFailed      = "job_failed"
+)
+
+// LogEvent é uma linha de log estruturada de uma operação.
+type LogEvent struct {
+	Time    time.Time `json:"time"`
+	Level   string    `json:"level"`
+	JobID   string    `json:"job_id,omitempty"`
+	Path    string    `json:"path,omitempty"`
+	Code    string    `json:"code,omitempty"`
+	Message string    `json:"message"` // texto no idioma do servidor
+	// Key e Params permitem que cada cliente exiba a mensagem no próprio idioma.
+	Key    string         `json:"key,omitempty"`
+	Params map[string]any `json:"params,omitempty"`
+}
+
+func newLogEvent(level, jobID, code, path string, text Text) LogEvent {
+	return LogEvent{Time: time.Now(), Level: level, JobID: jobID, Path: path, Code: code,
+		Message: text.String(), Key: text.Key, Params: text.Params}
+}
+
+// fileError associa um erro ao arquivo (caminho relativo) e ao código que o originou.
+type fileError struct {
+	Code string
+	Path string
+	Err  error
+}
+
+func (e *fileError) Error() string { return e.Err.Error() }
+
+func (e *fileError) Unwrap() error { return e.Err }
+
+// jobIDPattern valida IDs recebidos pela API antes de montar caminhos de arquivo.
+var jobIDPattern = regexp.MustCompile(`^[0-9]{8}-[0-9]{6}-[a-z]+-[0-9a-f]{8}$`)
+
+// newJobID gera IDs como "20240101-120000-collect-1a2b3c4d", ordenáveis pela data.
+func newJobID(kind string) string {
+	var suffix [4]byte
+	rand.Read(suffix[:])
+	return fmt.Sprintf("%s-%s-%s", time.Now().Format("20060102-150405"), kind, hex.EncodeToString(suffix[:]))
+}
+
+type jobLogFile struct {
+	file *os.File
+	buf  *bufio.Writer
+}
+
+// jobLogStore grava os eventos de cada operação em <dir>/<job_id>.jsonl.
+// As escritas são bufferizadas e descarregadas a cada envio do Hub.
+type jobLogStore struct {
+	mu   sync.Mutex
+	dir  string
+	open map[string]*jobLogFile
+}
+
+var jobLogs = &jobLogStore{dir: jobLogDir, open: make(map[string]*jobLogFile)}
+
+func (s *jobLogStore) path(jobID string) string {
+	return filepath.Join(s.dir, jobID+".jsonl")
+}
+
+func (s *jobLogStore) Write(ev LogEvent) {
+	if ev.JobID == "" {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	f, ok := s.open[ev.JobID]
+	if !ok {
+		file, err := os.OpenFile(s.path(ev.JobID), os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0o644)
+		if err != nil {
+			log.Printf("Falha ao abrir log da operação %s: %v", ev.JobID, err)
+			return
+		}
+		f = &jobLogFile{file: file, buf: bufio.NewWriter(file)}
+		s.open[ev.JobID] = f
+	}
+	json.NewEncoder(f.buf).Encode(ev)
+}
+
+func (s *jobLogStore) Flush() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, f := range s.open {
+		f.buf.Flush()
+	}
+}
+
+// Close descarrega e fecha o log da operação encerrada.
+func (s *jobLogStore) Close(jobID string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if f, ok := s.open[jobID]; ok {
+		f.buf.Flush()
+		f.file.Close()
+		delete(s.open, jobID)
+	}
+}
+
+// Remove apaga o log de uma operação que saiu do histórico.
+func (s *jobLogStore) Remove(jobID string) {
+	s.Close(jobID)
+	if err := os.Remove(s.path(jobID)); err != nil && !errors.Is(err, os.ErrNotExist) {
+		log.Printf("Falha ao remover log da operação %s: %v", jobID, err)
+	}
+}
+
+//================================================================//
+// HISTÓRICO DE OPERAÇÕES
+//================================================================//
+
+// maxJobHistory limita quantas operações o histórico guarda; os logs das
+// operações que saem do histórico são apagados.
+const maxJobHistory = 500
+
+// JobRecord resume uma operação no histórico.
+type JobRecord struct {
+	JobID      string     `json:"job_id"`
+	Kind       string     `json:"kind"`
+	Status     string     `json:"status"` // "running", "finished", "failed" ou "canceled"
+	StartedAt  time.Time  `json:"started_at"`
+	FinishedAt *time.Time `json:"finished_at,omitempty"`
+	Errors     int64      `json:"errors"`
+	Error      string     `json:"error,omitempty"`
+	Reports    []string   `json:"reports,omitempty"` // relatórios lidos ou gravados
+}
+
+// JobHistory guarda as operações mais recentes em <dir>/history.json.
+type JobHistory struct {
+	mu       sync.Mutex
+	fileName string
+	records  []JobRecord // da mais antiga para a mais recente
+}
+
+var jobHistory = &JobHistory{fileName: filepath.Join(jobLogDir, "history.json")}
+
+// Load lê o histórico salvo. Operações que constavam em andamento foram
+// interrompidas pelo encerramento do servidor e são marcadas como falhas.
+func (h *JobHistory) Load() error {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if err := readJSONFile(h.fileName, &h.records); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+	for i := range h.records {
+		if h.records[i].Status == "running" {
+			h.records[i].Status = "failed"
+			h.records[i].Error = "servidor encerrado durante a operação"
+		}
+	}
+	return nil
+}
+
+func (h *JobHistory) Start(jobID, kind string) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.records = append(h.records, JobRecord{JobID: jobID, Kind: kind, Status: "running", StartedAt: time.Now()})
+	if extra := len(h.records) - maxJobHistory; extra > 0 {
+		for _, old := range h.records[:extra] {
+			jobLogs.Remove(old.JobID)
+		}
+		h.records = h.records[extra:]
+	}
+	h.saveLocked()
+}
+
+func (h *JobHistory) Finish(jobID, status string, errorCount int64, err error) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	for i := len(h.records) - 1; i >= 0; i-- {
+		if h.records[i].JobID != jobID {
+			continue
+		}
+		now := time.Now()
+		h.records[i].Status, h.records[i].FinishedAt, h.records[i].Errors = status, &now, errorCount
+		if err != nil {
+			h.records[i].Error = err.Error()
+		}
+		break
+	}
+	h.saveLocked()
+}
+
+// AddReports associa à operação os relatórios que ela lê ou grava.
+func (h *JobHistory) AddReports(jobID string, names ...string) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	for i := len(h.records) - 1; i >= 0; i-- {
+		if h.records[i].JobID == jobID {
+			h.records[i].Reports = append(h.records[i].Reports, names...)
+			h.saveLocked()
+			return
+		}
+	}
+}
+
+// ReportsInUse retorna os relatórios de que uma operação ainda pode precisar:
+// os da operação em andamento e os da última operação de cada tipo, se ela
+// falhou ou foi cancelada e pode ser repetida a partir dos mesmos relatórios.
+// As chaves são os nomes sem o sufixo .gz; os valores, os IDs das operações.
+func (h *JobHistory) ReportsInUse() map[string]string {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	inUse := make(map[string]string)
+	settled := make(map[string]bool) // tipos cuja operação mais recente já terminou
+	for i := len(h.records) - 1; i >= 0; i-- {
+		rec := h.records[i]
+		resumable := rec.Status == "running" ||
+			!settled[rec.Kind] && (rec.Status == "failed" || rec.Status == "canceled")
+		if rec.Status != "running" {
+			settled[rec.Kind] = true
+		}
+		if !resumable {
+			continue
+		}
+		for _, name := range rec.Reports {
+			if _, ok := inUse[strings.TrimSuffix(name, ".gz")]; !ok {
+				inUse[strings.TrimSuffix(name, ".gz")] = rec.JobID
+			}
+		}
+	}
+	return inUse
+}
+
+// List retorna o histórico da operação mais recente para a mais antiga.
+func (h *JobHistory) List() []JobRecord {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	list := make([]JobRecord, len(h.records))
+	for i, rec := range h.records {
+		list[len(h.records)-1-i] = rec
+	}
+	return list
+}
+
+func (h *JobHistory) Get(jobID string) (JobRecord, bool) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	for _, rec := range h.records {
+		if rec.JobID == jobID {
+			return rec, true
+		}
+	}
+	return JobRecord{}, false
+}
+
+func (h *JobHistory) saveLocked() {
+	if err := writeJSONFile(h.fileName, h.records); err != nil {
+		log.Printf("Falha ao salvar histórico de operações: %v", err)
+	}
+}
+
+//================================================================//
+// HTTP
+//================================================================//
+
+// handleJobLog envia o log JSONL completo de uma operação para download.
+func handleJobLog(w http.ResponseWriter, r *http.Request) {
+	jobID := r.PathValue("id")
+	if !jobIDPattern.MatchString(jobID) {
+		writeError(w, validationError("id", "ID de operação inválido."))
+		return
+	}
+	jobLogs.Flush()
+	file, err := os.Open(jobLogs.path(jobID))
+	if err != nil {
+		writeError(w, fmt.Errorf("log da operação %s %w", jobID, errNotFound))
+		return
+	}
+	defer file.Close()
+	w.Header().Set("Content-Type", "application/x-ndjson")
+	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", jobID+".jsonl"))
+	http.ServeContent(w, r, jobID+".jsonl", time.Time{}, file)
+}
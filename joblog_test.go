@@ -0,0 +1,49 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestJobHistoryEvictsLogs(t *testing.T) {
+	dir := t.TempDir()
+	savedLogs := jobLogs
+	jobLogs = &jobLogStore{dir: dir, open: make(map[string]*jobLogFile)}
+	t.Cleanup(func() { jobLogs = savedLogs })
+
+	h := &JobHistory{fileName: filepath.Join(dir, "history.json")}
+	for i := range maxJobHistory {
+		h.records = append(h.records, JobRecord{JobID: fmt.Sprintf("job-%03d", i), Status: "finished"})
+	}
+	for _, id := range []string{"job-000", "job-002"} {
+		if err := os.WriteFile(jobLogs.path(id), []byte("{}\n"), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// O log da operação mais antiga ainda está aberto.
+	jobLogs.Write(LogEvent{JobID: "job-001", Message: "x"})
+
+	h.Start("nova-1", "collect")
+	h.Start("nova-2", "collect")
+
+	if len(h.records) != maxJobHistory {
+		t.Fatalf("%d operações no histórico, esperado %d", len(h.records), maxJobHistory)
+	}
+	if first := h.records[0].JobID; first != "job-002" {
+		t.Errorf("operação mais antiga = %s, esperado job-002", first)
+	}
+	for _, id := range []string{"job-000", "job-001"} {
+		if _, err := os.Stat(jobLogs.path(id)); !errors.Is(err, os.ErrNotExist) {
+			t.Errorf("log de %s não removido (erro %v)", id, err)
+		}
+	}
+	if _, ok := jobLogs.open["job-001"]; ok {
+		t.Error("log removido continua aberto")
+	}
+	if _, err := os.Stat(jobLogs.path("job-002")); err != nil {
+		t.Errorf("log de job-002 removido: %v", err)
+	}
+}
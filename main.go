@@ -1,17 +1,20 @@
 package main
 
 import (
+	"bytes"
 	"context"
 	"crypto/sha256"
 	"encoding/json"
+	"errors"
 	"fmt"
-	"html/template"
 	"io"
 	"log"
+	"maps"
 	"net/http"
 	"os"
 	"path/filepath"
 	"runtime"
+	"slices"
 	"sync"
 	"sync/atomic"
 	"time"
@@ -25,16 +28,29 @@ import (
 
 // FileMetadata armazena informações sobre um único arquivo.
 type FileMetadata struct {
-	Path    string    `json:"path"`
-	Size    int64     `json:"size"`
-	ModTime time.Time `json:"mod_time"`
-	Hash    string    `json:"hash"`
+	Path       string            `json:"path"`
+	Size       int64             `json:"size"`
+	ModTime    time.Time         `json:"mod_time"`
+	Hash       string            `json:"hash"`
+	Mode       string            `json:"mode,omitempty"`        // permissões em octal, ex.: "0644"
+	Owner      *FileOwner        `json:"owner,omitempty"`       // ausente fora do Linux
+	LinkTarget string            `json:"link_target,omitempty"` // destino de um link simbólico, que não é seguido
+	LinkID     string            `json:"link_id,omitempty"`     // "dispositivo:inode", só para arquivos com mais de um hard link
+	Special    string            `json:"special,omitempty"`     // "fifo", "socket", "device", "char_device" ou "irregular"; não é lido nem copiado
+	Xattrs     map[string][]byte `json:"xattrs,omitempty"`      // atributos estendidos, se coletados
+}
+
+// FileOwner identifica o dono de um arquivo pelos ids numéricos.
+type FileOwner struct {
+	UID int `json:"uid"`
+	GID int `json:"gid"`
 }
 
 // CollectionReport armazena o resultado de uma varredura de diretório.
 type CollectionReport struct {
 	Type      string         `json:"type"`
 	RootPath  string         `json:"root_path"`
+	HashMode  string         `json:"hash_mode,omitempty"`
 	Files     []FileMetadata `json:"files"`
 	Timestamp time.Time      `json:"timestamp"`
 }
@@ -43,20 +59,76 @@ type CollectionReport struct {
 type ComparisonResult struct {
 	SourceReport      string         `json:"source_report"`
 	DestinationReport string         `json:"destination_report"`
+	SourceRoot        string         `json:"source_root"`
+	DestinationRoot   string         `json:"destination_root"`
 	MissingInDest     []FileMetadata `json:"missing_in_dest"`
 	DifferentInDest   []FileMetadata `json:"different_in_dest"`
 	OnlyInDest        []FileMetadata `json:"only_in_dest"`
+	MetadataChanged   []FileMetadata `json:"metadata_changed"` // mesmo conteúdo, mas permissões, dono, data ou xattrs diferentes
+	Directories       []DirSummary   `json:"directories"`
 	Timestamp         time.Time      `json:"timestamp"`
 }
 
+// DirSummary totaliza as diferenças de um diretório, somando as de todos os
+// seus subdiretórios. Só aparecem diretórios com alguma diferença.
+type DirSummary struct {
+	Path            string    `json:"path"` // relativo à raiz e separado por "/"; "." é a própria raiz
+	Missing         DiffTotal `json:"missing"`
+	Different       DiffTotal `json:"different"`
+	OnlyInDest      DiffTotal `json:"only_in_dest"`
+	MetadataChanged DiffTotal `json:"metadata_changed"`
+}
+
+// DiffTotal é a quantidade e o tamanho dos arquivos de uma categoria de diferença.
+type DiffTotal struct {
+	Files int64 `json:"files"`
+	Bytes int64 `json:"bytes"`
+}
+
+// CopyFailure descreve um arquivo que não pôde ser copiado.
+type CopyFailure struct {
+	Path  string `json:"path"`
+	Error string `json:"error"`
+}
+
+// CopyReport armazena o resultado de uma cópia.
+type CopyReport struct {
+	ComparisonReport string         `json:"comparison_report"`
+	Copied           []FileMetadata `json:"copied"`
+	Failed           []CopyFailure  `json:"failed"`
+	MetadataFixed    []string       `json:"metadata_fixed"` // arquivos que tiveram apenas os metadados ajustados
+	Skipped          []string       `json:"skipped"`        // arquivos especiais, que não são copiados
+	Deleted          []string       `json:"deleted"`
+	Timestamp        time.Time      `json:"timestamp"`
+}
+
+// Diretórios de saída dos relatórios e da configuração.
+const (
+	collectedDir  = "collected_data"
+	comparisonDir = "comparison_results"
+	copyDir       = "copy_results"
+	configDir     = "config"
+	jobLogDir     = "job_logs"
+)
+
 // WSMessage define a estrutura de mensagens enviadas pelo WebSocket.
 type WSMessage struct {
-	Type       string  `json:"type"` // "log", "progress", "status"
-	Message    string  `json:"message"`
-	Total      int64   `json:"total"`
-	Processed  int64   `json:"processed"`
-	Percentage float64 `json:"percentage"`
-	Status     string  `json:"status"` // "idle", "running", "paused", "canceled", "finished"
+	Type           string         `json:"type"` // "log", "logs", "progress", "status"
+	Message        string         `json:"message"`
+	MessageKey     string         `json:"message_key,omitempty"` // chave de Message no catálogo de mensagens
+	MessageParams  map[string]any `json:"message_params,omitempty"`
+	Events         []LogEvent     `json:"events,omitempty"` // lote de eventos de log ("logs")
+	JobID          string         `json:"job_id,omitempty"`
+	Total          int64          `json:"total"`
+	Processed      int64          `json:"processed"`
+	TotalBytes     int64          `json:"total_bytes"`
+	ProcessedBytes int64          `json:"processed_bytes"`
+	BytesPerSecond float64        `json:"bytes_per_second"` // média móvel exponencial
+	ETASeconds     float64        `json:"eta_seconds"`      // -1 quando desconhecido
+	Workers        []string       `json:"workers"`          // arquivo atual de cada worker
+	Errors         int64          `json:"errors"`
+	Percentage     float64        `json:"percentage"`
+	Status         string         `json:"status"` // "idle", "running", "paused", "canceled", "finished"
 }
 
 // StateManager gerencia o estado da operação atual.
@@ -67,30 +139,175 @@ type StateManager struct {
 	isPaused       atomic.Bool
 	processedItems atomic.Int64
 	totalItems     atomic.Int64
+	processedBytes atomic.Int64
+	totalBytes     atomic.Int64
+	errorCount     atomic.Int64
+
+	// Protegidos por mu.
+	jobID       string
+	jobKind     string
+	workers     []string
+	stageStart  time.Time
+	sampleTime  time.Time
+	sampleBytes int64
+	throughput  float64
 }
 
-func (sm *StateManager) Start(ctx context.Context, cancel context.CancelFunc) {
+// throughputAlpha é o peso da amostra mais recente na média móvel de vazão.
+const throughputAlpha = 0.3
+
+// TryStart registra uma nova operação do tipo kind ("collect", "compare",
+// "copy", "sync" ou "watch") somente se nenhuma outra estiver em andamento,
+// e retorna o ID gerado para ela.
+func (sm *StateManager) TryStart(kind string, cancel context.CancelFunc) (string, bool) {
 	sm.mu.Lock()
 	defer sm.mu.Unlock()
+	if sm.status == "running" || sm.status == "paused" {
+		return "", false
+	}
+	sm.jobID = newJobID(kind)
+	sm.jobKind = kind
 	sm.status = "running"
 	sm.cancelFunc = cancel
 	sm.isPaused.Store(false)
-	sm.processedItems.Store(0)
-	sm.totalItems.Store(0)
+	sm.errorCount.Store(0)
+	sm.resetStageLocked(0, 0)
+	return sm.jobID, true
+}
+
+// JobID retorna o ID da operação atual ou da última operação executada.
+func (sm *StateManager) JobID() string {
+	sm.mu.Lock()
+	defer sm.mu.Unlock()
+	return sm.jobID
+}
+
+// JobKind retorna o tipo da operação atual ou da última operação executada.
+func (sm *StateManager) JobKind() string {
+	sm.mu.Lock()
+	defer sm.mu.Unlock()
+	return sm.jobKind
+}
+
+// ActiveJobID retorna o ID da operação em andamento, ou "" se não houver nenhuma.
+func (sm *StateManager) ActiveJobID() string {
+	sm.mu.Lock()
+	defer sm.mu.Unlock()
+	if sm.status != "running" && sm.status != "paused" {
+		return ""
+	}
+	return sm.jobID
 }
 
 func (sm *StateManager) SetTotal(total int64) {
 	sm.totalItems.Store(total)
 }
 
+// ResetProgress zera os contadores de itens e bytes para uma nova etapa da
+// operação. totalBytes igual a zero indica que a etapa não mede bytes.
+func (sm *StateManager) ResetProgress(totalItems, totalBytes int64) {
+	sm.mu.Lock()
+	defer sm.mu.Unlock()
+	sm.resetStageLocked(totalItems, totalBytes)
+}
+
+func (sm *StateManager) resetStageLocked(totalItems, totalBytes int64) {
+	sm.processedItems.Store(0)
+	sm.totalItems.Store(totalItems)
+	sm.processedBytes.Store(0)
+	sm.totalBytes.Store(totalBytes)
+	sm.workers = nil
+	sm.stageStart = time.Now()
+	sm.sampleTime = sm.stageStart
+	sm.sampleBytes = 0
+	sm.throughput = 0
+}
+
 func (sm *StateManager) IncrementProcessed() int64 {
 	return sm.processedItems.Add(1)
 }
 
+func (sm *StateManager) AddProcessedBytes(n int64) {
+	sm.processedBytes.Add(n)
+}
+
+func (sm *StateManager) IncrementErrors() {
+	sm.errorCount.Add(1)
+}
+
 func (sm *StateManager) GetProgress() (int64, int64) {
 	return sm.processedItems.Load(), sm.totalItems.Load()
 }
 
+// SetWorkers define quantos workers a etapa atual utiliza.
+func (sm *StateManager) SetWorkers(n int) {
+	sm.mu.Lock()
+	defer sm.mu.Unlock()
+	sm.workers = make([]string, n)
+}
+
+// SetWorkerFile registra o arquivo que o worker id está processando ("" quando ocioso).
+func (sm *StateManager) SetWorkerFile(id int, path string) {
+	sm.mu.Lock()
+	defer sm.mu.Unlock()
+	if id < len(sm.workers) {
+		sm.workers[id] = path
+	}
+}
+
+// Snapshot monta a mensagem de progresso atual, atualizando a média móvel de
+// vazão e a estimativa de tempo restante.
+func (sm *StateManager) Snapshot(statusMsg Text) WSMessage {
+	sm.mu.Lock()
+	defer sm.mu.Unlock()
+
+	msg := WSMessage{
+		Type:           "progress",
+		JobID:          sm.jobID,
+		Status:         sm.status,
+		Message:        statusMsg.String(),
+		MessageKey:     statusMsg.Key,
+		MessageParams:  statusMsg.Params,
+		Total:          sm.totalItems.Load(),
+		Processed:      sm.processedItems.Load(),
+		TotalBytes:     sm.totalBytes.Load(),
+		ProcessedBytes: sm.processedBytes.Load(),
+		Errors:         sm.errorCount.Load(),
+		Workers:        append([]string(nil), sm.workers...),
+		ETASeconds:     -1,
+	}
+
+	now := time.Now()
+	if elapsed := now.Sub(sm.sampleTime).Seconds(); elapsed >= 0.5 {
+		instant := float64(msg.ProcessedBytes-sm.sampleBytes) / elapsed
+		if sm.throughput == 0 {
+			sm.throughput = instant
+		} else {
+			sm.throughput = throughputAlpha*instant + (1-throughputAlpha)*sm.throughput
+		}
+		sm.sampleTime, sm.sampleBytes = now, msg.ProcessedBytes
+	}
+	msg.BytesPerSecond = sm.throughput
+
+	switch {
+	case msg.TotalBytes > 0:
+		msg.Percentage = float64(msg.ProcessedBytes) / float64(msg.TotalBytes) * 100
+		if sm.throughput > 0 {
+			msg.ETASeconds = float64(msg.TotalBytes-msg.ProcessedBytes) / sm.throughput
+		}
+	case msg.Total > 0:
+		msg.Percentage = float64(msg.Processed) / float64(msg.Total) * 100
+		if msg.Processed > 0 {
+			perItem := now.Sub(sm.stageStart).Seconds() / float64(msg.Processed)
+			msg.ETASeconds = perItem * float64(msg.Total-msg.Processed)
+		}
+	}
+	if msg.Status != "running" {
+		msg.ETASeconds = -1
+	}
+	return msg
+}
+
 func (sm *StateManager) Pause() {
 	sm.mu.Lock()
 	defer sm.mu.Unlock()
@@ -124,6 +341,12 @@ func (sm *StateManager) Finish() {
 	sm.status = "finished"
 }
 
+func (sm *StateManager) Status() string {
+	sm.mu.Lock()
+	defer sm.mu.Unlock()
+	return sm.status
+}
+
 func (sm *StateManager) IsRunning() bool {
 	sm.mu.Lock()
 	defer sm.mu.Unlock()
@@ -138,77 +361,245 @@ var state = &StateManager{status: "idle"}
 //================================================================//
 
 var upgrader = websocket.Upgrader{
-	CheckOrigin: func(r *http.Request) bool { return true },
+	CheckOrigin: checkSameOrigin,
+}
+
+const (
+	// broadcastInterval define a frequência (10 Hz) com que logs e progresso
+	// acumulados são enviados aos clientes.
+	broadcastInterval = 100 * time.Millisecond
+	// clientQueueSize é o número de mensagens pendentes por cliente antes
+	// que ele seja considerado lento e desconectado.
+	clientQueueSize = 64
+	// maxPendingLogs limita as linhas de log acumuladas entre dois envios.
+	maxPendingLogs = 1000
+	// logHistorySize é o número de linhas recentes reenviadas a quem se conecta.
+	logHistorySize = 500
+	writeTimeout   = 10 * time.Second
+)
+
+// wsClient é uma conexão WebSocket com sua própria fila de envio, esvaziada
+// por uma goroutine dedicada para que um navegador lento não trave as operações.
+type wsClient struct {
+	conn *websocket.Conn
+	send chan []byte
+}
+
+func (c *wsClient) writePump() {
+	defer c.conn.Close()
+	for data := range c.send {
+		c.conn.SetWriteDeadline(time.Now().Add(writeTimeout))
+		if err := c.conn.WriteMessage(websocket.TextMessage, data); err != nil {
+			log.Printf("Erro no websocket: %v", err)
+			return
+		}
+	}
+	c.conn.SetWriteDeadline(time.Now().Add(writeTimeout))
+	c.conn.WriteMessage(websocket.CloseMessage, websocket.FormatCloseMessage(websocket.CloseNormalClosure, ""))
 }
 
+// Hub acumula logs e atualizações de progresso e os distribui aos clientes
+// em intervalos fixos. Quem produz mensagens nunca bloqueia. As últimas linhas
+// já enviadas ficam em history para que clientes que se conectam no meio de
+// uma operação recebam o contexto recente e o progresso atual.
 type Hub struct {
-	clients    map[*websocket.Conn]bool
-	broadcast  chan WSMessage
-	register   chan *websocket.Conn
-	unregister chan *websocket.Conn
-	mu         sync.Mutex
+	mu            sync.Mutex
+	clients       map[*wsClient]bool
+	pendingLogs   []LogEvent
+	droppedLogs   int
+	history       []LogEvent
+	progressMsg   Text
+	progressDirty bool
 }
 
 func newHub() *Hub {
-	return &Hub{
-		broadcast:  make(chan WSMessage),
-		register:   make(chan *websocket.Conn),
-		unregister: make(chan *websocket.Conn),
-		clients:    make(map[*websocket.Conn]bool),
-	}
+	return &Hub{clients: make(map[*wsClient]bool)}
 }
 
 func (h *Hub) run() {
-	for {
+	ticker := time.NewTicker(broadcastInterval)
+	defer ticker.Stop()
+	for range ticker.C {
+		h.flush()
+	}
+}
+
+// flush envia os logs acumulados em lote e, se houve alteração, um único
+// retrato do progresso atual.
+func (h *Hub) flush() {
+	h.mu.Lock()
+	logs, dropped := h.pendingLogs, h.droppedLogs
+	progressMsg, dirty := h.progressMsg, h.progressDirty
+	h.pendingLogs, h.droppedLogs, h.progressDirty = nil, 0, false
+	h.mu.Unlock()
+
+	jobLogs.Flush()
+	if dropped > 0 {
+		omitted := newLogEvent(LevelWarn, "", "", "", T("log.dropped", "count", dropped))
+		logs = append([]LogEvent{omitted}, logs...)
+	}
+	if len(logs) > 0 {
+		h.broadcastLogs(logs)
+	}
+	if dirty {
+		h.broadcast(state.Snapshot(progressMsg))
+	}
+}
+
+// broadcastLogs envia um lote de logs e o registra no histórico. Ambos
+// acontecem sob h.mu para que um cliente recém-registrado não receba linhas
+// repetidas nem perca linhas.
+func (h *Hub) broadcastLogs(logs []LogEvent) {
+	data, err := json.Marshal(WSMessage{Type: "logs", Events: logs})
+	if err != nil {
+		log.Printf("Erro ao codificar mensagem: %v", err)
+		return
+	}
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.history = append(h.history, logs...)
+	if extra := len(h.history) - logHistorySize; extra > 0 {
+		h.history = append(h.history[:0:0], h.history[extra:]...)
+	}
+	h.sendLocked(data)
+}
+
+// broadcast enfileira a mensagem para todos os clientes.
+func (h *Hub) broadcast(msg WSMessage) {
+	data, err := json.Marshal(msg)
+	if err != nil {
+		log.Printf("Erro ao codificar mensagem: %v", err)
+		return
+	}
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.sendLocked(data)
+}
+
+// sendLocked enfileira data para todos os clientes, desconectando aqueles
+// cuja fila estiver cheia. Deve ser chamado com h.mu travado.
+func (h *Hub) sendLocked(data []byte) {
+	for c := range h.clients {
 		select {
-		case client := <-h.register:
-			h.mu.Lock()
-			h.clients[client] = true
-			h.mu.Unlock()
-		case client := <-h.unregister:
-			h.mu.Lock()
-			if _, ok := h.clients[client]; ok {
-				delete(h.clients, client)
-				client.Close()
-			}
-			h.mu.Unlock()
-		case message := <-h.broadcast:
-			h.mu.Lock()
-			for client := range h.clients {
-				err := client.WriteJSON(message)
-				if err != nil {
-					log.Printf("Erro no websocket: %v", err)
-					client.Close()
-					delete(h.clients, client)
-				}
-			}
-			h.mu.Unlock()
+		case c.send <- data:
+		default:
+			log.Printf("Cliente websocket lento desconectado: %s", c.conn.RemoteAddr())
+			h.removeLocked(c)
 		}
 	}
 }
 
-var hub *Hub
+// register adiciona o cliente e enfileira, antes de qualquer outra mensagem,
+// o histórico recente de logs e o retrato atual da operação.
+func (h *Hub) register(conn *websocket.Conn) *wsClient {
+	c := &wsClient{conn: conn, send: make(chan []byte, clientQueueSize)}
+	h.mu.Lock()
+	if len(h.history) > 0 {
+		if data, err := json.Marshal(WSMessage{Type: "logs", Events: h.history}); err == nil {
+			c.send <- data
+		}
+	}
+	if data, err := json.Marshal(state.Snapshot(h.progressTextLocked())); err == nil {
+		c.send <- data
+	}
+	h.clients[c] = true
+	h.mu.Unlock()
+	go c.writePump()
+	return c
+}
 
-// Função helper para enviar logs
-func sendLog(message string) {
-	hub.broadcast <- WSMessage{Type: "log", Message: message}
+func (h *Hub) unregister(c *wsClient) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.removeLocked(c)
 }
 
-// Função helper para enviar atualizações de status e progresso
-func sendProgressUpdate(statusMsg string) {
-	processed, total := state.GetProgress()
-	percentage := 0.0
-	if total > 0 {
-		percentage = (float64(processed) / float64(total)) * 100
+// removeLocked retira o cliente e encerra sua goroutine de escrita. Deve ser chamado com h.mu travado.
+func (h *Hub) removeLocked(c *wsClient) {
+	if _, ok := h.clients[c]; ok {
+		delete(h.clients, c)
+		close(c.send)
 	}
-	hub.broadcast <- WSMessage{
-		Type:       "progress",
-		Status:     state.status,
-		Message:    statusMsg,
-		Total:      total,
-		Processed:  processed,
-		Percentage: percentage,
+}
+
+// Log grava o evento no log da operação e o acumula para o próximo envio.
+// O arquivo recebe todos os eventos, mesmo os descartados do envio por excesso.
+func (h *Hub) Log(ev LogEvent) {
+	jobLogs.Write(ev)
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if len(h.pendingLogs) >= maxPendingLogs {
+		h.pendingLogs = h.pendingLogs[1:]
+		h.droppedLogs++
 	}
+	h.pendingLogs = append(h.pendingLogs, ev)
+}
+
+// ClientCount retorna quantos clientes WebSocket estão conectados.
+func (h *Hub) ClientCount() int {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return len(h.clients)
+}
+
+// Progress marca o progresso como alterado; apenas a última mensagem de
+// status de cada intervalo é enviada.
+func (h *Hub) Progress(statusMsg Text) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.progressMsg = statusMsg
+	h.progressDirty = true
+}
+
+// ProgressText retorna a última mensagem de status enviada.
+func (h *Hub) ProgressText() Text {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return h.progressTextLocked()
+}
+
+func (h *Hub) progressTextLocked() Text {
+	if h.progressMsg.Key == "" {
+		return T("status.idle")
+	}
+	return h.progressMsg
+}
+
+var hub *Hub
+
+// Função helper para enviar logs; key e args formam o Text (ver T)
+func sendLog(key string, args ...any) {
+	logEvent(LevelInfo, "", "", T(key, args...))
+}
+
+// Função helper para enviar avisos
+func sendWarn(key string, args ...any) {
+	logEvent(LevelWarn, "", "", T(key, args...))
+}
+
+// Função helper para enviar erros de arquivos individuais; eles são contabilizados no progresso
+func sendError(code, path, key string, args ...any) {
+	state.IncrementErrors()
+	metrics.FileFailed(code)
+	logEvent(LevelError, code, path, T(key, args...))
+}
+
+// Função helper para enviar o erro de um arquivo, usando o código e o caminho de um *fileError
+func sendFileError(defaultCode, path string, err error) {
+	var fe *fileError
+	if errors.As(err, &fe) {
+		defaultCode, path = fe.Code, fe.Path
+	}
+	sendError(defaultCode, path, "log.error", "error", err)
+}
+
+func logEvent(level, code, path string, text Text) {
+	hub.Log(newLogEvent(level, state.ActiveJobID(), code, path, text))
+}
+
+// Função helper para enviar atualizações de status e progresso
+func sendProgressUpdate(key string, args ...any) {
+	hub.Progress(T(key, args...))
 }
 
 //================================================================//
@@ -236,208 +627,1063 @@ func checkPauseAndCancel(ctx context.Context) error {
 }
 
 // --- Collector ---
-func CollectFiles(ctx context.Context, rootPath, reportType string) {
-	sendLog(fmt.Sprintf("Iniciando contagem de arquivos em: %s", rootPath))
-	var totalFiles int64
-	filepath.Walk(rootPath, func(path string, info os.FileInfo, err error) error {
-		if err == nil && !info.IsDir() {
-			totalFiles++
+func CollectFiles(ctx context.Context, rootPath, reportType string, opts SyncOptions) (string, error) {
+	now := time.Now()
+	reportName := reportFileName(fmt.Sprintf("%s_%s.jsonl", reportType, now.Format("20060102_150405")))
+	// As entradas são ordenadas por Path antes de gravadas, para que a
+	// comparação possa intercalar os relatórios sem indexá-los em memória.
+	sorter := newMetadataSorter(collectedDir)
+	defer sorter.Close()
+	if err := collectMetadata(ctx, rootPath, opts, sorter.Add); err != nil {
+		return "", err
+	}
+	report, err := createCollectionReport(filepath.Join(collectedDir, reportName),
+		CollectionHeader{Type: reportType, RootPath: rootPath, HashMode: opts.HashMode, Timestamp: now, Sorted: true,
+			Xattrs: opts.Xattrs && xattrsSupported})
+	if err != nil {
+		return "", err
+	}
+	for f, err := range sorter.Sorted() {
+		if err == nil {
+			err = report.Write(f)
 		}
+		if err != nil {
+			report.Abort()
+			return "", err
+		}
+	}
+	if err := report.Close(); err != nil {
+		return "", err
+	}
+	jobHistory.AddReports(state.JobID(), reportName)
+
+	sendLog("log.collect.finished", "report", filepath.Join(collectedDir, reportName))
+	return reportName, nil
+}
+
+// collectMetadata percorre rootPath em paralelo e entrega a emit os metadados
+// de cada arquivo não excluído, na ordem em que ficam prontos. Um erro de emit
+// interrompe a coleta.
+func collectMetadata(ctx context.Context, rootPath string, opts SyncOptions, emit func(FileMetadata) error) error {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	sendLog("log.collect.counting", "path", rootPath)
+	var totalFiles, totalBytes int64
+	walkIncluded(rootPath, opts, func(path string, info os.FileInfo) error {
+		totalFiles++
+		totalBytes += info.Size()
 		return nil
 	})
-	state.SetTotal(totalFiles)
-	sendLog(fmt.Sprintf("Total de arquivos encontrados: %d", totalFiles))
-	sendProgressUpdate("Iniciando coleta...")
+	// Sem hash, nenhum conteúdo é lido e o progresso é medido apenas em arquivos.
+	if opts.HashMode == "none" {
+		totalBytes = 0
+	}
+	state.ResetProgress(totalFiles, totalBytes)
+	sendLog("log.collect.total", "count", totalFiles)
+	sendProgressUpdate("status.collect.starting")
 
 	var wg sync.WaitGroup
 	numWorkers := runtime.NumCPU()
 	jobs := make(chan string, numWorkers)
 	results := make(chan FileMetadata, 1000)
+	state.SetWorkers(numWorkers)
 
 	for w := 0; w < numWorkers; w++ {
 		wg.Add(1)
-		go func() {
+		go func(id int) {
 			defer wg.Done()
+			defer state.SetWorkerFile(id, "")
 			for path := range jobs {
 				if err := checkPauseAndCancel(ctx); err != nil {
 					return
 				}
 
-				info, err := os.Stat(path)
-				if err != nil {
-					sendLog(fmt.Sprintf("ERRO: %s: %v", path, err))
-					continue
+				if err := throttle.WaitFile(ctx); err != nil {
+					return
 				}
-				hash, err := calculateHash(path)
+				state.SetWorkerFile(id, path)
+				meta, err := collectFile(ctx, rootPath, path, opts)
 				if err != nil {
-					sendLog(fmt.Sprintf("ERRO hash %s: %v", path, err))
+					sendFileError(CodeStatFailed, path, err)
 					continue
 				}
-				relPath, _ := filepath.Rel(rootPath, path)
-				results <- FileMetadata{Path: relPath, Size: info.Size(), ModTime: info.ModTime(), Hash: hash}
+				results <- meta
 
-				processedCount := state.IncrementProcessed()
-				sendProgressUpdate(fmt.Sprintf("Coletado: %s", relPath))
-				if processedCount == totalFiles {
-					close(results)
-				}
+				state.IncrementProcessed()
+				sendProgressUpdate("status.collect.file", "path", meta.Path)
 			}
-		}()
+		}(w)
 	}
 
 	go func() {
 		defer close(jobs)
-		filepath.Walk(rootPath, func(path string, info os.FileInfo, err error) error {
-			if err != nil {
-				return err
-			}
-			if !info.IsDir() {
-				select {
-				case jobs <- path:
-				case <-ctx.Done():
-					return ctx.Err()
-				}
+		walkIncluded(rootPath, opts, func(path string, info os.FileInfo) error {
+			select {
+			case jobs <- path:
+				return nil
+			case <-ctx.Done():
+				return ctx.Err()
 			}
-			return nil
 		})
 	}()
 
-	var collectedFiles []FileMetadata
+	// Fecha o canal de resultados somente quando todos os workers terminarem,
+	// inclusive quando algum arquivo falhar ou a operação for cancelada.
+	go func() {
+		wg.Wait()
+		close(results)
+	}()
+
+	// O canal é esvaziado até o fim mesmo após um erro, para liberar os workers.
+	var emitErr error
 	for res := range results {
-		collectedFiles = append(collectedFiles, res)
+		if emitErr == nil {
+			if emitErr = emit(res); emitErr != nil {
+				cancel()
+			}
+		}
+	}
+	if emitErr != nil {
+		return emitErr
 	}
 
 	// Verifica se a operação foi cancelada antes de salvar
-	if ctx.Err() != nil {
-		sendLog("Coleta cancelada pelo usuário.")
-		state.Finish()
-		sendProgressUpdate("Coleta cancelada.")
-		return
-	}
+	return ctx.Err()
+}
 
-	wg.Wait() // Garante que todos os workers terminaram
+// collectFile lê os metadados (e o hash, conforme opts.HashMode) de um único arquivo.
+func collectFile(ctx context.Context, rootPath, path string, opts SyncOptions) (FileMetadata, error) {
+	relPath, _ := filepath.Rel(rootPath, path)
+	info, err := os.Lstat(path)
+	if err != nil {
+		return FileMetadata{}, &fileError{Code: CodeStatFailed, Path: relPath, Err: fmt.Errorf("%s: %w", path, err)}
+	}
+	readPath := path
+	if info.Mode()&os.ModeSymlink != 0 && opts.SymlinkPolicy == "follow" {
+		// Links para diretórios só chegam aqui quando formam um ciclo (ver
+		// walkIncluded); eles, os links quebrados e os que apontam para fora
+		// dos diretórios permitidos são registrados como links.
+		resolved, target, err := followLink(path)
+		switch {
+		case err == nil && !target.IsDir():
+			info, readPath = target, resolved
+		case err == nil:
+			sendWarn("log.collect.symlink_cycle", "path", path)
+		case errors.Is(err, errOutsideRoots):
+			sendWarn("log.collect.symlink_outside", "path", path)
+		}
+	}
+	meta := FileMetadata{Path: relPath, Size: info.Size(), ModTime: info.ModTime(), Mode: fileMode(info)}
+	meta.Owner, meta.LinkID = fileOwner(info)
+	if info.Mode()&os.ModeSymlink != 0 {
+		// O link é registrado como tal, sem seguir o destino.
+		if meta.LinkTarget, err = os.Readlink(path); err != nil {
+			return FileMetadata{}, &fileError{Code: CodeStatFailed, Path: relPath, Err: fmt.Errorf("%s: %w", path, err)}
+		}
+		return meta, nil
+	}
+	if meta.Special = specialKind(info.Mode()); meta.Special != "" {
+		// FIFOs, sockets e dispositivos são registrados sem ser abertos: a
+		// leitura poderia bloquear ou não ter fim.
+		meta.Size = 0
+		return meta, nil
+	}
+	if opts.Xattrs && xattrsSupported {
+		if meta.Xattrs, err = readXattrs(readPath); err != nil {
+			return FileMetadata{}, &fileError{Code: CodeStatFailed, Path: relPath, Err: fmt.Errorf("xattrs %s: %w", path, err)}
+		}
+	}
+	if opts.HashMode != "none" {
+		meta.Hash, err = calculateHash(ctx, readPath)
+		if err != nil {
+			return FileMetadata{}, &fileError{Code: CodeHashFailed, Path: relPath, Err: fmt.Errorf("hash %s: %w", path, err)}
+		}
+	}
+	return meta, nil
+}
 
-	report := CollectionReport{Type: reportType, RootPath: rootPath, Files: collectedFiles, Timestamp: time.Now()}
-	fileName := fmt.Sprintf("collected_data/%s_%s.json", reportType, time.Now().Format("20060102_150405"))
-	file, _ := os.Create(fileName)
-	defer file.Close()
-	json.NewEncoder(file).Encode(report)
+// fileMode retorna as permissões de info em octal, com os bits setuid, setgid e sticky.
+func fileMode(info os.FileInfo) string {
+	mode := uint32(info.Mode().Perm())
+	if info.Mode()&os.ModeSetuid != 0 {
+		mode |= 0o4000
+	}
+	if info.Mode()&os.ModeSetgid != 0 {
+		mode |= 0o2000
+	}
+	if info.Mode()&os.ModeSticky != 0 {
+		mode |= 0o1000
+	}
+	return fmt.Sprintf("%04o", mode)
+}
 
-	sendLog(fmt.Sprintf("Coleta finalizada! Relatório salvo em: %s", fileName))
-	state.Finish()
-	sendProgressUpdate("Coleta finalizada!")
+// specialKind classifica os arquivos que não são regulares, diretórios nem
+// links simbólicos. Retorna "" para os demais.
+func specialKind(mode os.FileMode) string {
+	switch {
+	case mode&os.ModeNamedPipe != 0:
+		return "fifo"
+	case mode&os.ModeSocket != 0:
+		return "socket"
+	case mode&os.ModeCharDevice != 0:
+		return "char_device"
+	case mode&os.ModeDevice != 0:
+		return "device"
+	case mode&os.ModeIrregular != 0:
+		return "irregular"
+	}
+	return ""
 }
 
 // --- Comparator ---
-func CompareReports(ctx context.Context, sourceFile, destFile string) {
-	// Implementação similar com checkPauseAndCancel
-	// ... (código omitido por brevidade, mas a lógica é a mesma)
-	sendLog("Comparação finalizada!")
-	state.Finish()
-	sendProgressUpdate("Comparação finalizada!")
+func CompareReports(ctx context.Context, sourceFile, destFile string) (string, error) {
+	sourcePath, err := reportPath(collectedDir, sourceFile)
+	if err != nil {
+		return "", err
+	}
+	destPath, err := reportPath(collectedDir, destFile)
+	if err != nil {
+		return "", err
+	}
+	source, err := openCollectionReport(sourcePath)
+	if err != nil {
+		return "", fmt.Errorf("relatório de origem: %w", err)
+	}
+	defer source.Close()
+	dest, err := openCollectionReport(destPath)
+	if err != nil {
+		return "", fmt.Errorf("relatório de destino: %w", err)
+	}
+	defer dest.Close()
+	jobHistory.AddReports(state.JobID(), sourceFile, destFile)
+
+	sourceCount, err := source.Count()
+	if err != nil {
+		return "", fmt.Errorf("relatório de origem: %w", err)
+	}
+	destCount, err := dest.Count()
+	if err != nil {
+		return "", fmt.Errorf("relatório de destino: %w", err)
+	}
+	sendLog("log.compare.started", "source", sourceFile, "source_count", sourceCount, "dest", destFile, "dest_count", destCount)
+	state.ResetProgress(sourceCount, 0)
+	sendProgressUpdate("status.compare.starting")
+
+	// Os dois relatórios são percorridos em ordem de Path e intercalados, com
+	// memória constante; relatórios que não foram gravados ordenados são
+	// ordenados antes, em arquivos temporários.
+	sourceFiles, closeSource, err := sortedFiles(ctx, source)
+	if err != nil {
+		return "", fmt.Errorf("relatório de origem: %w", err)
+	}
+	defer closeSource()
+	destFiles, closeDest, err := sortedFiles(ctx, dest)
+	if err != nil {
+		return "", fmt.Errorf("relatório de destino: %w", err)
+	}
+	defer closeDest()
+	nextSource, stopSource := pullSorted(sourceFiles)
+	defer stopSource()
+	nextDest, stopDest := pullSorted(destFiles)
+	defer stopDest()
+
+	// Se algum dos lados foi coletado sem hash, a comparação usa tamanho e data
+	// de modificação. Os atributos estendidos só são comparados se ambos os
+	// lados os coletaram.
+	compareHashes := source.Header.HashMode != "none" && dest.Header.HashMode != "none"
+	compareXattrs := source.Header.Xattrs && dest.Header.Xattrs
+
+	reportName := reportFileName(fmt.Sprintf("comparison_%s.json", time.Now().Format("20060102_150405")))
+	out, err := createComparisonReport(filepath.Join(comparisonDir, reportName))
+	if err != nil {
+		return "", err
+	}
+	defer out.Abort()
+
+	err = mergeJoin(ctx, nextSource, nextDest, compareHashes, compareXattrs, out, func(s FileMetadata) {
+		if state.IncrementProcessed()%1000 == 0 {
+			sendProgressUpdate("status.compare.file", "path", s.Path)
+		}
+	})
+	if err != nil {
+		return "", err
+	}
+
+	err = out.Close(ComparisonResult{
+		SourceReport:      sourceFile,
+		DestinationReport: destFile,
+		SourceRoot:        source.Header.RootPath,
+		DestinationRoot:   dest.Header.RootPath,
+		Timestamp:         time.Now(),
+	})
+	if err != nil {
+		return "", err
+	}
+	jobHistory.AddReports(state.JobID(), reportName)
+	missing, different, onlyInDest, metadataChanged := out.Counts()
+	sendLog("log.compare.summary", "missing", missing, "different", different, "only_in_dest", onlyInDest,
+		"metadata_changed", metadataChanged)
+	sendLog("log.compare.finished", "report", filepath.Join(comparisonDir, reportName))
+	return reportName, nil
+}
+
+// mergeJoin intercala as entradas da origem e do destino, ambas em ordem
+// crescente de Path, registrando as diferenças em out. advanced é chamada para
+// cada entrada da origem já comparada.
+func mergeJoin(ctx context.Context, nextSource, nextDest func() (FileMetadata, bool, error),
+	compareHashes, compareXattrs bool, out *comparisonWriter, advanced func(FileMetadata)) error {
+	s, sok, err := nextSource()
+	if err != nil {
+		return fmt.Errorf("relatório de origem: %w", err)
+	}
+	d, dok, err := nextDest()
+	if err != nil {
+		return fmt.Errorf("relatório de destino: %w", err)
+	}
+	for sok || dok {
+		if err := checkPauseAndCancel(ctx); err != nil {
+			return err
+		}
+		switch {
+		case !dok || sok && s.Path < d.Path:
+			err = out.Missing(s)
+		case !sok || d.Path < s.Path:
+			err = out.OnlyInDest(d)
+		case filesDiffer(s, d, compareHashes):
+			err = out.Different(s)
+		case metadataDiffers(s, d, compareHashes, compareXattrs):
+			err = out.MetadataChanged(s)
+		}
+		if err != nil {
+			return err
+		}
+		advanceSource, advanceDest := sok && (!dok || s.Path <= d.Path), dok && (!sok || d.Path <= s.Path)
+		if advanceSource {
+			advanced(s)
+			if s, sok, err = nextSource(); err != nil {
+				return fmt.Errorf("relatório de origem: %w", err)
+			}
+		}
+		if advanceDest {
+			if d, dok, err = nextDest(); err != nil {
+				return fmt.Errorf("relatório de destino: %w", err)
+			}
+		}
+	}
+	return nil
+}
+
+// filesDiffer compara o conteúdo de uma entrada da origem com o da entrada de
+// mesmo caminho no destino. Links simbólicos são comparados pelo destino do
+// link e arquivos especiais, pelo tipo.
+func filesDiffer(s, d FileMetadata, compareHashes bool) bool {
+	switch {
+	case s.LinkTarget != "" || d.LinkTarget != "":
+		// A data de um link não é preservada na cópia; vale apenas o destino.
+		return s.LinkTarget != d.LinkTarget
+	case s.Special != d.Special:
+		return true
+	case s.Size != d.Size:
+		return true
+	case compareHashes:
+		return s.Hash != d.Hash
+	default:
+		return !s.ModTime.Truncate(time.Second).Equal(d.ModTime.Truncate(time.Second))
+	}
+}
+
+// metadataDiffers compara os metadados de duas entradas de mesmo conteúdo.
+// Permissões e dono só são comparados se os dois relatórios os registraram
+// (relatórios antigos não os têm). A data de modificação só entra aqui quando
+// o conteúdo foi comparado pelo hash (do contrário, ela já faz parte de
+// filesDiffer) e nunca para links, cuja data não é preservada na cópia.
+func metadataDiffers(s, d FileMetadata, compareHashes, compareXattrs bool) bool {
+	switch {
+	case compareHashes && s.LinkTarget == "" && !s.ModTime.Truncate(time.Second).Equal(d.ModTime.Truncate(time.Second)):
+		return true
+	case s.Mode != "" && d.Mode != "" && s.Mode != d.Mode:
+		return true
+	case s.Owner != nil && d.Owner != nil && *s.Owner != *d.Owner:
+		return true
+	case compareXattrs:
+		return !maps.EqualFunc(s.Xattrs, d.Xattrs, bytes.Equal)
+	}
+	return false
 }
 
 // --- Copier ---
-func CopyFiles(ctx context.Context, comparisonFile string) {
-	// Implementação similar com checkPauseAndCancel
-	// ... (código omitido por brevidade, mas a lógica é a mesma)
-	sendLog("Cópia finalizada!")
-	state.Finish()
-	sendProgressUpdate("Cópia finalizada!")
+func CopyFiles(ctx context.Context, comparisonFile string, opts SyncOptions) (string, error) {
+	comparisonPath, err := reportPath(comparisonDir, comparisonFile)
+	if err != nil {
+		return "", err
+	}
+	sourceRoot, destRoot, err := readComparisonRoots(comparisonPath)
+	if err != nil {
+		return "", err
+	}
+	jobHistory.AddReports(state.JobID(), comparisonFile)
+	if sourceRoot == "" || destRoot == "" {
+		return "", fmt.Errorf("relatório %s não informa os diretórios de origem e destino", comparisonFile)
+	}
+	// Os diretórios vêm do relatório e são verificados novamente, pois as raízes permitidas podem ter mudado.
+	if sourceRoot, err = sandbox.Resolve(sourceRoot); err != nil {
+		return "", err
+	}
+	if destRoot, err = sandbox.Resolve(destRoot); err != nil {
+		return "", err
+	}
+
+	// As listas do relatório são lidas uma entrada por vez, sem carregá-lo
+	// inteiro: uma primeira passagem só conta o que há a fazer.
+	toCopy := []string{"missing_in_dest"}
+	if opts.Copy.Overwrite {
+		toCopy = append(toCopy, "different_in_dest")
+	}
+	var pending, skipped, different, metadataChanged int
+	var pendingBytes int64
+	err = readComparisonEntries(comparisonPath, comparisonLists[:], func(list string, f FileMetadata) error {
+		switch {
+		case list == "different_in_dest" && !opts.Copy.Overwrite:
+			different++
+		case list == "metadata_changed":
+			metadataChanged++
+		case list == "only_in_dest":
+		case f.Special != "":
+			// Arquivos especiais (FIFOs, sockets, dispositivos) não são copiados.
+			skipped++
+		default:
+			pending++
+			if f.LinkTarget == "" {
+				pendingBytes += f.Size
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return "", err
+	}
+	if different > 0 {
+		sendWarn("log.copy.not_overwriting", "count", different)
+	}
+	if skipped > 0 {
+		sendWarn("log.copy.special_skipped", "count", skipped)
+	}
+	// Arquivos de mesmo conteúdo e metadados diferentes só têm os metadados ajustados.
+	fixups := 0
+	if opts.Copy.FixMetadata {
+		fixups = metadataChanged
+	} else if metadataChanged > 0 {
+		sendLog("log.copy.not_fixing_metadata", "count", metadataChanged)
+	}
+	state.ResetProgress(int64(pending+fixups), pendingBytes)
+	sendLog("log.copy.started", "count", pending, "source", sourceRoot, "dest", destRoot)
+	sendProgressUpdate("status.copy.starting")
+
+	report, err := createCopyReport(comparisonFile)
+	if err != nil {
+		return "", err
+	}
+	defer report.Abort()
+	var wg sync.WaitGroup
+	jobs := make(chan FileMetadata)
+	numWorkers := runtime.NumCPU()
+	state.SetWorkers(numWorkers)
+
+	// Uma falha ao gravar o relatório interrompe a cópia: sem ele, não haveria
+	// registro do que foi feito.
+	var reportErr error
+	var reportErrOnce sync.Once
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	record := func(err error) {
+		if err != nil {
+			reportErrOnce.Do(func() {
+				reportErr = err
+				cancel()
+			})
+		}
+	}
+
+	for w := 0; w < numWorkers; w++ {
+		wg.Add(1)
+		go func(id int) {
+			defer wg.Done()
+			defer state.SetWorkerFile(id, "")
+			for f := range jobs {
+				if err := checkPauseAndCancel(ctx); err != nil {
+					return
+				}
+				src := filepath.Join(sourceRoot, f.Path)
+				dst := filepath.Join(destRoot, f.Path)
+				if err := throttle.WaitFile(ctx); err != nil {
+					return
+				}
+				state.SetWorkerFile(id, f.Path)
+				err := copyEntry(ctx, src, destRoot, dst, f, opts.Copy.Preserve)
+				if err == nil && opts.Copy.Verify && f.Hash != "" && f.LinkTarget == "" {
+					err = verifyHash(ctx, dst, f.Hash)
+				}
+				if err != nil {
+					record(report.Failed(f.Path, err))
+					sendError(CodeCopyFailed, f.Path, "log.copy_failed", "path", f.Path, "error", err)
+				} else {
+					record(report.Copied(f))
+				}
+				state.IncrementProcessed()
+				sendProgressUpdate("status.copy.file", "path", f.Path)
+			}
+		}(w)
+	}
+
+	err = readComparisonEntries(comparisonPath, toCopy, func(list string, f FileMetadata) error {
+		if f.Special != "" {
+			return report.Skipped(f.Path)
+		}
+		select {
+		case jobs <- f:
+			return nil
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	})
+	close(jobs)
+	wg.Wait()
+	if reportErr != nil {
+		return "", reportErr
+	}
+	if err := ctx.Err(); err != nil {
+		return "", err
+	}
+	if err != nil {
+		return "", err
+	}
+
+	if fixups > 0 {
+		err = readComparisonEntries(comparisonPath, []string{"metadata_changed"}, func(list string, f FileMetadata) error {
+			if err := checkPauseAndCancel(ctx); err != nil {
+				return err
+			}
+			src := filepath.Join(sourceRoot, f.Path)
+			dst := filepath.Join(destRoot, f.Path)
+			if err := fixMetadata(src, dst, opts.Copy.Preserve); err != nil {
+				sendError(CodeMetadataFailed, f.Path, "log.metadata_failed", "path", f.Path, "error", err)
+				if err := report.Failed(f.Path, err); err != nil {
+					return err
+				}
+			} else if err := report.MetadataFixed(f.Path); err != nil {
+				return err
+			}
+			state.IncrementProcessed()
+			sendProgressUpdate("status.copy.metadata", "path", f.Path)
+			return nil
+		})
+		if err != nil {
+			return "", err
+		}
+		_, _, fixed, _, _ := report.Counts()
+		sendLog("log.copy.metadata_fixed", "count", fixed)
+	}
+
+	if opts.DeletionPolicy == "delete" || opts.DeletionPolicy == "trash" {
+		trashDir := filepath.Join(destRoot, trashDirName, time.Now().Format("20060102_150405"))
+		err = readComparisonEntries(comparisonPath, []string{"only_in_dest"}, func(list string, f FileMetadata) error {
+			if err := checkPauseAndCancel(ctx); err != nil {
+				return err
+			}
+			if err := removeFromDest(destRoot, f.Path, opts.DeletionPolicy, trashDir); err != nil {
+				sendError(CodeDeleteFailed, f.Path, "log.delete_failed", "path", f.Path, "error", err)
+				return report.Failed(f.Path, err)
+			}
+			return report.Deleted(f.Path)
+		})
+		if err != nil {
+			return "", err
+		}
+		_, _, _, _, deleted := report.Counts()
+		sendLog("log.copy.deleted", "policy", opts.DeletionPolicy, "count", deleted)
+	}
+
+	timestamp := time.Now()
+	reportName := reportFileName(fmt.Sprintf("copy_%s.json", timestamp.Format("20060102_150405")))
+	if err := report.Close(filepath.Join(copyDir, reportName), timestamp); err != nil {
+		return "", err
+	}
+	jobHistory.AddReports(state.JobID(), reportName)
+	copied, failed, _, _, _ := report.Counts()
+	sendLog("log.copy.summary", "copied", copied, "failed", failed)
+	sendLog("log.copy.finished", "report", filepath.Join(copyDir, reportName))
+	return reportName, nil
+}
+
+// --- Sync (coleta + comparação + cópia encadeadas) ---
+func SyncDirectories(ctx context.Context, sourcePath, destPath string, opts SyncOptions) error {
+	sourceReport, err := CollectFiles(ctx, sourcePath, "source", opts)
+	if err != nil {
+		return err
+	}
+	destReport, err := CollectFiles(ctx, destPath, "destination", opts)
+	if err != nil {
+		return err
+	}
+	comparisonReport, err := CompareReports(ctx, sourceReport, destReport)
+	if err != nil {
+		return err
+	}
+	_, err = CopyFiles(ctx, comparisonReport, opts)
+	return err
+}
+
+// runOperation executa uma operação já registrada no StateManager e publica o
+// resultado final (sucesso, falha ou cancelamento) para os clientes.
+func runOperation(ctx context.Context, op func(ctx context.Context) error) error {
+	jobID, kind, start := state.JobID(), state.JobKind(), time.Now()
+	name := T("job." + kind)
+	defer jobLogs.Close(jobID)
+	jobHistory.Start(jobID, kind)
+
+	err := op(ctx)
+	switch {
+	case errors.Is(err, context.Canceled):
+		jobHistory.Finish(jobID, "canceled", state.errorCount.Load(), nil)
+		metrics.JobFinished(kind, "canceled", time.Since(start))
+		sendWarn("log.job.canceled", "job", name)
+		state.Finish()
+		sendProgressUpdate("status.job.canceled", "job", name)
+	case err != nil:
+		jobHistory.Finish(jobID, "failed", state.errorCount.Load(), err)
+		metrics.JobFinished(kind, "failed", time.Since(start))
+		logEvent(LevelError, CodeJobFailed, "", T("log.job.failed", "job", name, "error", err))
+		state.Finish()
+		sendProgressUpdate("status.job.failed", "job", name)
+	default:
+		jobHistory.Finish(jobID, "finished", state.errorCount.Load(), nil)
+		metrics.JobFinished(kind, "finished", time.Since(start))
+		state.Finish()
+		sendProgressUpdate("status.job.finished", "job", name)
+	}
+	return err
 }
 
 // --- Funções auxiliares (calculateHash, etc.) ---
-func calculateHash(filePath string) (string, error) {
+func calculateHash(ctx context.Context, filePath string) (string, error) {
+	return hashFile(ctx, filePath, true)
+}
+
+// hashFile calcula o SHA-256 do arquivo; progress indica se os bytes lidos
+// contam para o progresso da etapa atual.
+func hashFile(ctx context.Context, filePath string, progress bool) (string, error) {
 	file, err := os.Open(filePath)
 	if err != nil {
 		return "", err
 	}
 	defer file.Close()
 	hash := sha256.New()
-	if _, err := io.Copy(hash, file); err != nil {
+	if _, err := io.Copy(hash, &throttledReader{ctx: ctx, r: file, progress: progress}); err != nil {
 		return "", err
 	}
+	metrics.filesHashed.Add(1)
 	return fmt.Sprintf("%x", hash.Sum(nil)), nil
 }
 
+// walkIncluded percorre rootPath chamando fn para cada entrada que não seja
+// diretório nem corresponda às exclusões. Diretórios excluídos não são
+// percorridos. Links simbólicos seguem opts.SymlinkPolicy: "skip" os ignora,
+// "link" os entrega como links e "follow" entrega o arquivo apontado ou
+// percorre o diretório apontado. Um link para um diretório que já está no
+// caminho percorrido (um ciclo), um link quebrado e um link para fora dos
+// diretórios permitidos são entregues como links.
+func walkIncluded(rootPath string, opts SyncOptions, fn func(path string, info os.FileInfo) error) error {
+	info, err := os.Stat(rootPath)
+	if err != nil || !info.IsDir() {
+		return nil
+	}
+	return walkDir(rootPath, rootPath, opts, []os.FileInfo{info}, fn)
+}
+
+// walkDir percorre dir, cujos ancestrais até rootPath (inclusive o próprio
+// dir) estão em ancestors. Diretórios ilegíveis são ignorados.
+func walkDir(rootPath, dir string, opts SyncOptions, ancestors []os.FileInfo, fn func(path string, info os.FileInfo) error) error {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil
+	}
+	for _, e := range entries {
+		path := filepath.Join(dir, e.Name())
+		relPath, _ := filepath.Rel(rootPath, path)
+		if opts.isExcluded(relPath) {
+			continue
+		}
+		info, err := e.Info()
+		if err != nil {
+			continue
+		}
+		if info.Mode()&os.ModeSymlink != 0 {
+			switch opts.SymlinkPolicy {
+			case "skip":
+				continue
+			case "follow":
+				_, target, err := followLink(path)
+				if err != nil {
+					break
+				}
+				if target.IsDir() && slices.ContainsFunc(ancestors, func(a os.FileInfo) bool { return os.SameFile(a, target) }) {
+					break
+				}
+				info = target
+			}
+		}
+		if info.IsDir() {
+			if err := walkDir(rootPath, path, opts, append(ancestors, info), fn); err != nil {
+				return err
+			}
+			continue
+		}
+		if err := fn(path, info); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// followLink resolve o link simbólico path para a política "follow",
+// retornando o caminho canônico do destino e suas informações. Um destino fora
+// dos diretórios permitidos não é seguido (o erro envolve errOutsideRoots).
+func followLink(path string) (string, os.FileInfo, error) {
+	resolved, err := sandbox.Resolve(path)
+	if err != nil {
+		return "", nil, err
+	}
+	target, err := os.Stat(resolved)
+	if err != nil {
+		return "", nil, err
+	}
+	return resolved, target, nil
+}
+
+// copyEntry copia uma entrada de um relatório de src para dst, que deve ficar
+// dentro de destRoot: links simbólicos são recriados como links e os demais
+// arquivos têm o conteúdo copiado.
+func copyEntry(ctx context.Context, src, destRoot, dst string, f FileMetadata, preserve PreserveOptions) error {
+	if f.LinkTarget != "" {
+		return copyLink(src, destRoot, dst, preserve)
+	}
+	// Arquivos alcançados por links seguidos na coleta são lidos pelo caminho
+	// canônico, que não pode ter saído dos diretórios permitidos desde então.
+	src, err := sandbox.Resolve(src)
+	if err != nil {
+		return err
+	}
+	return copyFile(ctx, src, destRoot, dst, preserve)
+}
+
+// copyLink recria em dst o link simbólico src, com o mesmo destino (que não é
+// verificado nem seguido), substituindo o que houver em dst.
+func copyLink(src, destRoot, dst string, preserve PreserveOptions) error {
+	info, err := os.Lstat(src)
+	if err != nil {
+		return err
+	}
+	target, err := os.Readlink(src)
+	if err != nil {
+		return err
+	}
+	dst, err = makeDestinationDirs(destRoot, dst)
+	if err != nil {
+		return err
+	}
+	if err := os.Remove(dst); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+	if err := os.Symlink(target, dst); err != nil {
+		return err
+	}
+	metrics.filesCopied.Add(1)
+	return applyMetadata(src, dst, info, preserve)
+}
+
+// copyFile copia o conteúdo de src para dst, criando os diretórios
+// necessários dentro de destRoot e preservando a data de modificação da origem
+// e os demais metadados escolhidos em preserve.
+func copyFile(ctx context.Context, src, destRoot, dst string, preserve PreserveOptions) error {
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+	info, err := in.Stat()
+	if err != nil {
+		return err
+	}
+	dst, err = makeDestinationDirs(destRoot, dst)
+	if err != nil {
+		return err
+	}
+	// Um link no destino é substituído, e não seguido: os.Create gravaria no arquivo apontado.
+	if di, err := os.Lstat(dst); err == nil && di.Mode()&os.ModeSymlink != 0 {
+		if err := os.Remove(dst); err != nil {
+			return err
+		}
+	}
+	out, err := os.Create(dst)
+	if err != nil {
+		return err
+	}
+	n, err := io.Copy(out, &throttledReader{ctx: ctx, r: in, progress: true})
+	metrics.bytesWritten.Add(n)
+	if err != nil {
+		out.Close()
+		return err
+	}
+	if err := out.Close(); err != nil {
+		return err
+	}
+	metrics.filesCopied.Add(1)
+	return applyMetadata(src, dst, info, preserve)
+}
+
+// fixMetadata aplica a dst, cujo conteúdo já é igual ao de src, os metadados
+// atuais de src.
+func fixMetadata(src, dst string, preserve PreserveOptions) error {
+	info, err := os.Lstat(src)
+	if err != nil {
+		return err
+	}
+	if _, err := os.Lstat(dst); err != nil {
+		return err
+	}
+	return applyMetadata(src, dst, info, preserve)
+}
+
+// applyMetadata aplica a dst os metadados de src (descritos por info) escolhidos
+// em p. A data de modificação é sempre aplicada, pois a comparação por tamanho
+// e data depende dela.
+func applyMetadata(src, dst string, info os.FileInfo, p PreserveOptions) error {
+	owner, _ := fileOwner(info)
+	// Só root pode atribuir o arquivo a outro usuário; nos demais casos o dono
+	// fica sendo quem executa o servidor.
+	chown := p.Owner && owner != nil && os.Geteuid() == 0
+	if info.Mode()&os.ModeSymlink != 0 {
+		// Permissões e datas seriam aplicadas ao destino do link; apenas o dono é do próprio link.
+		if chown {
+			return os.Lchown(dst, owner.UID, owner.GID)
+		}
+		return nil
+	}
+	if p.Xattrs && xattrsSupported {
+		attrs, err := readXattrs(src)
+		if err != nil {
+			return fmt.Errorf("xattrs: %w", err)
+		}
+		if err := writeXattrs(dst, attrs); err != nil {
+			return fmt.Errorf("xattrs: %w", err)
+		}
+	}
+	if chown {
+		if err := os.Lchown(dst, owner.UID, owner.GID); err != nil {
+			return err
+		}
+	}
+	// O chmod vem depois do chown, que remove os bits setuid e setgid.
+	if p.Mode {
+		if err := os.Chmod(dst, info.Mode()&(os.ModePerm|os.ModeSetuid|os.ModeSetgid|os.ModeSticky)); err != nil {
+			return err
+		}
+	}
+	atime := info.ModTime()
+	if t := fileAtime(info); p.Atime && !t.IsZero() {
+		atime = t
+	}
+	return os.Chtimes(dst, atime, info.ModTime())
+}
+
+func verifyHash(ctx context.Context, filePath, expected string) error {
+	hash, err := hashFile(ctx, filePath, false)
+	if err != nil {
+		return err
+	}
+	if hash != expected {
+		return fmt.Errorf("hash da cópia não confere (esperado %s, obtido %s)", expected, hash)
+	}
+	return nil
+}
+
+// removeFromDest aplica a política de exclusão a um arquivo existente apenas no
+// destino: "delete" remove o arquivo e "trash" o move para trashDir.
+func removeFromDest(destRoot, relPath, policy, trashDir string) error {
+	target, err := destinationPath(destRoot, filepath.Join(destRoot, relPath))
+	if err != nil {
+		return err
+	}
+	if policy == "delete" {
+		return os.Remove(target)
+	}
+	trashed, err := makeDestinationDirs(destRoot, filepath.Join(trashDir, relPath))
+	if err != nil {
+		return err
+	}
+	return os.Rename(target, trashed)
+}
+
+func writeJSONFile(fileName string, v any) error {
+	file, err := os.Create(fileName)
+	if err != nil {
+		return err
+	}
+	if err := json.NewEncoder(file).Encode(v); err != nil {
+		file.Close()
+		return err
+	}
+	return file.Close()
+}
+
+func readJSONFile(fileName string, v any) error {
+	file, err := os.Open(fileName)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	return json.NewDecoder(file).Decode(v)
+}
+
 //================================================================//
 // 4. HTTP HANDLERS
 //================================================================//
 
 func handleCollect(w http.ResponseWriter, r *http.Request) {
-	if state.IsRunning() {
-		http.Error(w, "Uma operação já está em andamento.", http.StatusConflict)
-		return
-	}
 	var req struct {
-		Path string `json:"path"`
-		Type string `json:"type"`
+		Path     string            `json:"path"`
+		Type     string            `json:"type"`
+		Profile  string            `json:"profile"`
+		Throttle *ThrottleSettings `json:"throttle"`
+	}
+	if err := decodeJSON(w, r, &req); err != nil {
+		writeError(w, err)
+		return
 	}
-	json.NewDecoder(r.Body).Decode(&req)
-
-	ctx, cancel := context.WithCancel(context.Background())
-	state.Start(ctx, cancel)
 
-	go CollectFiles(ctx, req.Path, req.Type)
+	opts, err := resolveProfileOptions(req.Profile)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	if req.Throttle != nil {
+		if err := req.Throttle.validate(); err != nil {
+			writeError(w, validationError("throttle", "%v", err))
+			return
+		}
+		opts.Throttle = *req.Throttle
+	}
+	if req.Type != "source" && req.Type != "destination" {
+		writeError(w, validationError("type", "tipo de coleta deve ser \"source\" ou \"destination\""))
+		return
+	}
+	if req.Path == "" && req.Profile != "" {
+		p, _ := profiles.Get(req.Profile)
+		req.Path = p.SourcePath
+		if req.Type == "destination" {
+			req.Path = p.DestPath
+		}
+	}
+	root, err := validateDirectory("path", req.Path)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
 
-	w.WriteHeader(http.StatusOK)
+	startJob(w, "collect", &opts.Throttle, func(ctx context.Context) error {
+		_, err := CollectFiles(ctx, root, req.Type, opts)
+		return err
+	})
 }
 
 func handleCompare(w http.ResponseWriter, r *http.Request) {
-	if state.IsRunning() {
-		http.Error(w, "Uma operação já está em andamento.", http.StatusConflict)
-		return
-	}
 	var req struct {
 		SourceFile string `json:"source_file"`
 		DestFile   string `json:"dest_file"`
 	}
-	json.NewDecoder(r.Body).Decode(&req)
-
-	ctx, cancel := context.WithCancel(context.Background())
-	state.Start(ctx, cancel)
-
-	go CompareReports(ctx, req.SourceFile, req.DestFile) // Simplificado
+	if err := decodeJSON(w, r, &req); err != nil {
+		writeError(w, err)
+		return
+	}
+	if err := validateCollectionReport("source_file", req.SourceFile, "source"); err != nil {
+		writeError(w, err)
+		return
+	}
+	if err := validateCollectionReport("dest_file", req.DestFile, "destination"); err != nil {
+		writeError(w, err)
+		return
+	}
 
-	w.WriteHeader(http.StatusOK)
+	startJob(w, "compare", nil, func(ctx context.Context) error {
+		_, err := CompareReports(ctx, req.SourceFile, req.DestFile)
+		return err
+	})
 }
 
 func handleCopy(w http.ResponseWriter, r *http.Request) {
-	if state.IsRunning() {
-		http.Error(w, "Uma operação já está em andamento.", http.StatusConflict)
-		return
-	}
 	var req struct {
-		ComparisonFile string `json:"comparison_file"`
+		ComparisonFile string            `json:"comparison_file"`
+		Profile        string            `json:"profile"`
+		Throttle       *ThrottleSettings `json:"throttle"`
+	}
+	if err := decodeJSON(w, r, &req); err != nil {
+		writeError(w, err)
+		return
 	}
-	json.NewDecoder(r.Body).Decode(&req)
-
-	ctx, cancel := context.WithCancel(context.Background())
-	state.Start(ctx, cancel)
 
-	go CopyFiles(ctx, req.ComparisonFile) // Simplificado
+	opts, err := resolveProfileOptions(req.Profile)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	if req.Throttle != nil {
+		if err := req.Throttle.validate(); err != nil {
+			writeError(w, validationError("throttle", "%v", err))
+			return
+		}
+		opts.Throttle = *req.Throttle
+	}
+	if err := validateComparisonReport("comparison_file", req.ComparisonFile); err != nil {
+		writeError(w, err)
+		return
+	}
 
-	w.WriteHeader(http.StatusOK)
+	startJob(w, "copy", &opts.Throttle, func(ctx context.Context) error {
+		_, err := CopyFiles(ctx, req.ComparisonFile, opts)
+		return err
+	})
 }
 
 func handlePause(w http.ResponseWriter, r *http.Request) {
+	if state.Status() != "running" {
+		writeError(w, apiError(http.StatusConflict, ErrCodeNoOperation, "Nenhuma operação em execução."))
+		return
+	}
 	state.Pause()
-	sendLog("Operação pausada.")
-	sendProgressUpdate("Pausado")
-	w.WriteHeader(http.StatusOK)
+	sendLog("log.job.paused")
+	sendProgressUpdate("status.paused")
+	writeJSON(w, http.StatusOK, OperationStatus{JobID: state.JobID(), Status: state.Status()})
 }
 
 func handleResume(w http.ResponseWriter, r *http.Request) {
+	if state.Status() != "paused" {
+		writeError(w, apiError(http.StatusConflict, ErrCodeNoOperation, "Nenhuma operação pausada."))
+		return
+	}
 	state.Resume()
-	sendLog("Operação retomada.")
-	sendProgressUpdate("Executando...")
-	w.WriteHeader(http.StatusOK)
+	sendLog("log.job.resumed")
+	sendProgressUpdate("status.running")
+	writeJSON(w, http.StatusOK, OperationStatus{JobID: state.JobID(), Status: state.Status()})
 }
 
 func handleCancel(w http.ResponseWriter, r *http.Request) {
+	if !state.IsRunning() {
+		writeError(w, apiError(http.StatusConflict, ErrCodeNoOperation, "Nenhuma operação em andamento."))
+		return
+	}
 	state.Cancel()
 	// O log e a atualização de status serão feitos pela própria goroutine ao detectar o cancelamento.
-	w.WriteHeader(http.StatusOK)
+	writeJSON(w, http.StatusOK, OperationStatus{JobID: state.JobID(), Status: state.Status()})
 }
 
 func serveWs(w http.ResponseWriter, r *http.Request) {
@@ -446,8 +1692,8 @@ func serveWs(w http.ResponseWriter, r *http.Request) {
 		log.Println(err)
 		return
 	}
-	hub.register <- conn
-	defer func() { hub.unregister <- conn }()
+	client := hub.register(conn)
+	defer hub.unregister(client)
 	for {
 		if _, _, err := conn.ReadMessage(); err != nil {
 			break
@@ -459,180 +1705,17 @@ func serveWs(w http.ResponseWriter, r *http.Request) {
 // 5. FRONTEND
 //================================================================//
 
-const indexHTML = `
-<!DOCTYPE html>
-<html lang="pt-br">
-<head>
-    <meta charset="UTF-8">
-    <meta name="viewport" content="width=device-width, initial-scale=1.0">
-    <title>GoLang Sync Tool</title>
-    <link href="https://fonts.googleapis.com/css2?family=Roboto:wght@300;400;700&display=swap" rel="stylesheet">
-    <style>
-        body { font-family: 'Roboto', sans-serif; background-color: #121212; color: #e0e0e0; margin: 0; padding: 20px; display: flex; flex-direction: column; align-items: center; }
-        .container { width: 90%; max-width: 1200px; background-color: #1e1e1e; padding: 25px; border-radius: 8px; box-shadow: 0 4px 8px rgba(0,0,0,0.3); }
-        h1, h2 { color: #bb86fc; border-bottom: 2px solid #373737; padding-bottom: 10px; font-weight: 300; }
-        .card { background-color: #2c2c2c; padding: 20px; border-radius: 6px; margin-bottom: 20px; }
-        label { display: block; margin-bottom: 8px; font-weight: 700; color: #cfcfcf; }
-        input[type="text"] { width: calc(100% - 22px); padding: 10px; border-radius: 4px; border: 1px solid #444; background-color: #333; color: #e0e0e0; font-size: 16px; }
-        button { background-color: #03dac6; color: #121212; border: none; padding: 12px 20px; border-radius: 4px; cursor: pointer; font-size: 16px; font-weight: 700; transition: background-color 0.3s ease; margin-top: 10px; }
-        button:hover { background-color: #018786; }
-        button:disabled { background-color: #555; cursor: not-allowed; }
-        #logs { background-color: #252525; height: 300px; overflow-y: scroll; padding: 15px; border-radius: 6px; border: 1px solid #373737; font-family: 'Courier New', Courier, monospace; font-size: 14px; white-space: pre-wrap; word-wrap: break-word; margin-top: 20px; }
-        .progress-container { margin-top: 20px; background-color: #373737; border-radius: 6px; padding: 15px; }
-        #progress-bar { width: 100%; height: 25px; -webkit-appearance: none; appearance: none; border-radius: 5px; overflow: hidden; }
-        #progress-bar::-webkit-progress-bar { background-color: #444; }
-        #progress-bar::-webkit-progress-value { background-color: #03dac6; transition: width 0.2s ease-in-out; }
-        #progress-text { margin-top: 10px; text-align: center; font-size: 16px; }
-        .controls button { margin-right: 10px; background-color: #f44336; color: white; }
-        .controls #btn-pause { background-color: #ff9800;}
-        .controls #btn-resume { background-color: #4caf50; display: none; }
-    </style>
-</head>
-<body>
-    <div class="container">
-        <h1>GoLang High Performance Sync Tool</h1>
-
-        <div class="progress-container">
-            <h2>Status da Operação</h2>
-            <div id="progress-text">Ocioso</div>
-            <progress id="progress-bar" value="0" max="100"></progress>
-            <div class="controls">
-                <button id="btn-pause" disabled>Pausar</button>
-                <button id="btn-resume" disabled>Retomar</button>
-                <button id="btn-cancel" disabled>Cancelar</button>
-            </div>
-        </div>
-
-        <div class="card">
-            <h2>1. Coletar Dados</h2>
-            <label for="source-path">Caminho da Origem:</label>
-            <input type="text" id="source-path" placeholder="Ex: C:\Users\nome\Documentos">
-            <button id="collect-source">Coletar Origem</button>
-            <br><br>
-            <label for="dest-path">Caminho do Destino:</label>
-            <input type="text" id="dest-path" placeholder="Ex: D:\Backup">
-            <button id="collect-dest">Coletar Destino</button>
-        </div>
-
-        <div class="card">
-            <h2>2. Comparar Relatórios</h2>
-            <label for="source-json">Arquivo JSON da Origem:</label>
-            <input type="text" id="source-json" placeholder="Ex: source_20230101_120000.json">
-            <br><br>
-            <label for="dest-json">Arquivo JSON do Destino:</label>
-            <input type="text" id="dest-json" placeholder="Ex: destination_20230101_120500.json">
-            <button id="compare-jsons">Comparar</button>
-        </div>
-
-        <div class="card">
-            <h2>3. Copiar Arquivos</h2>
-            <label for="comparison-json">Arquivo JSON de Comparação:</label>
-            <input type="text" id="comparison-json" placeholder="Ex: comparison_20230101_121000.json">
-            <button id="copy-files">Iniciar Cópia</button>
-        </div>
-
-        <h2>Logs em Tempo Real</h2>
-        <div id="logs">Conectando ao servidor...</div>
-    </div>
-
-    <script>
-        document.addEventListener('DOMContentLoaded', () => {
-            const logs = document.getElementById('logs');
-            const progressBar = document.getElementById('progress-bar');
-            const progressText = document.getElementById('progress-text');
-
-            const btnPause = document.getElementById('btn-pause');
-            const btnResume = document.getElementById('btn-resume');
-            const btnCancel = document.getElementById('btn-cancel');
-            
-            const actionButtons = [
-                document.getElementById('collect-source'),
-                document.getElementById('collect-dest'),
-                document.getElementById('compare-jsons'),
-                document.getElementById('copy-files')
-            ];
-
-            const ws = new WebSocket('ws://' + window.location.host + '/ws');
-
-            function setControlsState(status) {
-                const isRunning = status === 'running';
-                const isPaused = status === 'paused';
-                const isIdle = status === 'idle' || status === 'finished' || status === 'canceled';
-
-                btnPause.style.display = isPaused ? 'none' : 'inline-block';
-                btnResume.style.display = isPaused ? 'inline-block' : 'none';
-
-                btnPause.disabled = !isRunning;
-                btnResume.disabled = !isPaused;
-                btnCancel.disabled = isIdle;
-
-                actionButtons.forEach(btn => btn.disabled = !isIdle);
-            }
-
-            ws.onopen = () => { logs.innerHTML = 'Conectado ao servidor com sucesso.\n'; };
-            ws.onclose = () => { logs.innerHTML += 'Conexão perdida.\n'; setControlsState('idle'); };
-
-            ws.onmessage = (event) => {
-                const data = JSON.parse(event.data);
-
-                if (data.type === 'log') {
-                    logs.innerHTML += data.message + '\n';
-                    logs.scrollTop = logs.scrollHeight;
-                } else if (data.type === 'progress') {
-                    progressBar.value = data.percentage;
-                    progressText.textContent = data.message + ' (' + data.processed + ' / ' + data.total + ') - ' + data.percentage.toFixed(2) + '%';
-                    setControlsState(data.status);
-                }
-            };
-
-            function postRequest(url, body = {}) {
-                return fetch(url, { method: 'POST', body: JSON.stringify(body) });
-            }
-
-            actionButtons.forEach(btn => {
-                btn.addEventListener('click', (e) => {
-                    let url, body;
-                    switch(e.target.id) {
-                        case 'collect-source':
-                            url = '/collect';
-                            body = { path: document.getElementById('source-path').value, type: 'source' };
-                            break;
-                        case 'collect-dest':
-                            url = '/collect';
-                            body = { path: document.getElementById('dest-path').value, type: 'destination' };
-                            break;
-                        case 'compare-jsons':
-                            url = '/compare';
-                            body = { source_file: document.getElementById('source-json').value, dest_file: document.getElementById('dest-json').value };
-                            break;
-                        case 'copy-files':
-                             url = '/copy';
-                             body = { comparison_file: document.getElementById('comparison-json').value };
-                             break;
-                    }
-                    if (body.path === '' || body.source_file === '' || body.comparison_file === '') {
-                        alert('Por favor, preencha os campos necessários.');
-                        return;
-                    }
-                    postRequest(url, body);
-                });
-            });
-
-            btnPause.addEventListener('click', () => postRequest('/pause'));
-            btnResume.addEventListener('click', () => postRequest('/resume'));
-            btnCancel.addEventListener('click', () => postRequest('/cancel'));
-            
-            setControlsState('idle');
-        });
-    </script>
-</body>
-</html>
-`
-
 func serveHome(w http.ResponseWriter, r *http.Request) {
-	w.Header().Set("Content-Type", "text/html; charset=utf-8")
-	tmpl, _ := template.New("index").Parse(indexHTML)
-	tmpl.Execute(w, nil)
+	if r.URL.Path != "/" {
+		writeError(w, fmt.Errorf("%s %w", r.URL.Path, errNotFound))
+		return
+	}
+	serveWebAsset(w, r, "index.html")
+}
+
+// serveViewer envia o visualizador de relatórios de comparação (/viewer?name=).
+func serveViewer(w http.ResponseWriter, r *http.Request) {
+	serveWebAsset(w, r, "viewer.html")
 }
 
 //================================================================//
@@ -640,25 +1723,76 @@ func serveHome(w http.ResponseWriter, r *http.Request) {
 //================================================================//
 
 func main() {
-	os.MkdirAll("collected_data", os.ModePerm)
-	os.MkdirAll("comparison_results", os.ModePerm)
+	if runAuthCommand(os.Args[1:]) {
+		return
+	}
+
+	for _, dir := range []string{collectedDir, comparisonDir, copyDir, configDir, jobLogDir} {
+		os.MkdirAll(dir, os.ModePerm)
+	}
+
+	sandbox = newPathSandbox(filepath.Join(configDir, "allowed_roots.json"))
+	if err := sandbox.Load(); err != nil {
+		log.Fatalf("Falha ao carregar diretórios permitidos: %v", err)
+	}
+
+	auth = newAuthStore(filepath.Join(configDir, "auth.json"))
+	if err := auth.Load(); err != nil {
+		log.Fatalf("Falha ao carregar usuários: %v", err)
+	}
+
+	if err := jobHistory.Load(); err != nil {
+		log.Printf("Falha ao carregar histórico de operações: %v", err)
+	}
 
 	hub = newHub()
 	go hub.run()
 
-	http.HandleFunc("/", serveHome)
-	http.HandleFunc("/ws", serveWs)
-	http.HandleFunc("/collect", handleCollect)
-	http.HandleFunc("/compare", handleCompare)
-	http.HandleFunc("/copy", handleCopy)
-	http.HandleFunc("/pause", handlePause)
-	http.HandleFunc("/resume", handleResume)
-	http.HandleFunc("/cancel", handleCancel)
+	profiles = newProfileStore(filepath.Join(configDir, "profiles.json"))
+	if err := profiles.Load(); err != nil {
+		log.Printf("Falha ao carregar perfis: %v", err)
+	}
 
-	port := "8080"
-	log.Printf("Servidor iniciado em http://localhost:%s", port)
-	err := http.ListenAndServe(":"+port, nil)
+	serverConfig, err := loadServerConfig(filepath.Join(configDir, "server.json"))
 	if err != nil {
+		log.Fatalf("Falha ao carregar config/server.json: %v", err)
+	}
+	serverLanguage = serverConfig.Language
+	reportConfig = serverConfig.Reports
+	go runReportMaintenance()
+
+	scheduler = newScheduler(filepath.Join(configDir, "schedules.json"))
+	if err := scheduler.Load(); err != nil {
+		log.Printf("Falha ao carregar agendamentos: %v", err)
+	}
+	go scheduler.run()
+
+	get, post := http.MethodGet, http.MethodPost
+	http.HandleFunc("/", allowMethods(serveHome, get))
+	http.HandleFunc("/viewer", allowMethods(serveViewer, get))
+	http.HandleFunc("/ws", allowMethods(serveWs, get))
+	http.HandleFunc("/static/", allowMethods(handleStatic, get))
+	http.HandleFunc("/login", allowMethods(handleLogin, get, post))
+	http.HandleFunc("/logout", allowMethods(handleLogout, post))
+	http.HandleFunc("/auth/me", allowMethods(handleAuthMe, get))
+	http.HandleFunc("/collect", allowMethods(handleCollect, post))
+	http.HandleFunc("/compare", allowMethods(handleCompare, post))
+	http.HandleFunc("/copy", allowMethods(handleCopy, post))
+	http.HandleFunc("/pause", allowMethods(handlePause, post))
+	http.HandleFunc("/resume", allowMethods(handleResume, post))
+	http.HandleFunc("/cancel", allowMethods(handleCancel, post))
+	http.HandleFunc("/schedules", allowMethods(handleSchedules, get, post, http.MethodDelete))
+	http.HandleFunc("/profiles", allowMethods(handleProfiles, get, post, http.MethodPut, http.MethodDelete))
+	http.HandleFunc("/sync", allowMethods(handleSync, post))
+	http.HandleFunc("/watch", allowMethods(handleWatch, get, post))
+	http.HandleFunc("/throttle", allowMethods(handleThrottle, get, post))
+	http.HandleFunc("/jobs/{id}/log", allowMethods(handleJobLog, get))
+	http.HandleFunc("/fs/list", allowMethods(handleListDir, get))
+	http.HandleFunc("/reports/{name}", allowMethods(handleDeleteReportByName, http.MethodDelete))
+	http.HandleFunc("/metrics", allowMethods(handleMetrics, get))
+	registerAPIv1(http.DefaultServeMux)
+
+	if err := listenAndServe(serverConfig, requireAuth(http.DefaultServeMux)); err != nil {
 		log.Fatalf("Falha ao iniciar o servidor: %v", err)
 	}
 }
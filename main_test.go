@@ -0,0 +1,538 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+// changed retorna uma cópia de f com as alterações feitas por change.
+func changed(f FileMetadata, change func(*FileMetadata)) FileMetadata {
+	change(&f)
+	return f
+}
+
+func TestFilesDiffer(t *testing.T) {
+	base := time.Date(2026, 3, 1, 10, 0, 0, 0, time.UTC)
+	file := FileMetadata{Path: "a", Size: 10, ModTime: base, Hash: "h1"}
+	tests := []struct {
+		name          string
+		s, d          FileMetadata
+		compareHashes bool
+		want          bool
+	}{
+		{"iguais", file, file, false, false},
+		{"tamanho", file, changed(file, func(f *FileMetadata) { f.Size = 11 }), false, true},
+		{"data", file, changed(file, func(f *FileMetadata) { f.ModTime = base.Add(time.Minute) }), false, true},
+		{"data abaixo de um segundo", file, changed(file, func(f *FileMetadata) { f.ModTime = base.Add(300 * time.Millisecond) }), false, false},
+		{"data com hash igual", file, changed(file, func(f *FileMetadata) { f.ModTime = base.Add(time.Minute) }), true, false},
+		{"hash", file, changed(file, func(f *FileMetadata) { f.Hash = "h2" }), true, true},
+		{"hash ignorado", file, changed(file, func(f *FileMetadata) { f.Hash = "h2" }), false, false},
+		{"link igual com datas diferentes",
+			FileMetadata{Path: "l", LinkTarget: "x", ModTime: base},
+			FileMetadata{Path: "l", LinkTarget: "x", ModTime: base.Add(time.Hour)}, false, false},
+		{"link com outro destino", FileMetadata{LinkTarget: "x"}, FileMetadata{LinkTarget: "y"}, false, true},
+		{"arquivo virou link", file, changed(file, func(f *FileMetadata) { f.LinkTarget = "x" }), false, true},
+		{"especial", FileMetadata{Special: "fifo"}, FileMetadata{}, false, true},
+		{"especiais iguais", FileMetadata{Special: "fifo"}, FileMetadata{Special: "fifo"}, false, false},
+	}
+	for _, tt := range tests {
+		if got := filesDiffer(tt.s, tt.d, tt.compareHashes); got != tt.want {
+			t.Errorf("%s: filesDiffer = %v, esperado %v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestMetadataDiffers(t *testing.T) {
+	base := time.Date(2026, 3, 1, 10, 0, 0, 0, time.UTC)
+	file := FileMetadata{Path: "a", ModTime: base, Mode: "0644", Owner: &FileOwner{UID: 1000, GID: 1000}}
+	tests := []struct {
+		name                         string
+		s, d                         FileMetadata
+		compareHashes, compareXattrs bool
+		want                         bool
+	}{
+		{"iguais", file, file, false, false, false},
+		{"permissões", file, changed(file, func(f *FileMetadata) { f.Mode = "0600" }), false, false, true},
+		{"permissões ausentes no destino", file, changed(file, func(f *FileMetadata) { f.Mode = "" }), false, false, false},
+		{"dono", file, changed(file, func(f *FileMetadata) { f.Owner = &FileOwner{UID: 0, GID: 0} }), false, false, true},
+		{"dono ausente no destino", file, changed(file, func(f *FileMetadata) { f.Owner = nil }), false, false, false},
+		{"data com hash", file, changed(file, func(f *FileMetadata) { f.ModTime = base.Add(time.Minute) }), true, false, true},
+		{"data sem hash", file, changed(file, func(f *FileMetadata) { f.ModTime = base.Add(time.Minute) }), false, false, false},
+		{"data de link",
+			FileMetadata{LinkTarget: "x", ModTime: base},
+			FileMetadata{LinkTarget: "x", ModTime: base.Add(time.Minute)}, true, false, false},
+		{"xattrs", file, changed(file, func(f *FileMetadata) { f.Xattrs = map[string][]byte{"user.a": []byte("1")} }), false, true, true},
+		{"xattrs ignorados", file, changed(file, func(f *FileMetadata) { f.Xattrs = map[string][]byte{"user.a": []byte("1")} }), false, false, false},
+	}
+	for _, tt := range tests {
+		if got := metadataDiffers(tt.s, tt.d, tt.compareHashes, tt.compareXattrs); got != tt.want {
+			t.Errorf("%s: metadataDiffers = %v, esperado %v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestMergeJoin(t *testing.T) {
+	base := time.Date(2026, 3, 1, 10, 0, 0, 0, time.UTC)
+	source := []FileMetadata{
+		{Path: "a", Size: 1, ModTime: base},
+		{Path: "b", Size: 1, ModTime: base},
+		{Path: "c", Size: 1, ModTime: base, Mode: "0644"},
+		{Path: "e", Size: 1, ModTime: base},
+		{Path: "g", Size: 1, ModTime: base},
+	}
+	dest := []FileMetadata{
+		{Path: "0", Size: 1, ModTime: base},
+		{Path: "b", Size: 2, ModTime: base},
+		{Path: "c", Size: 1, ModTime: base, Mode: "0600"},
+		{Path: "d", Size: 1, ModTime: base},
+		{Path: "e", Size: 1, ModTime: base},
+	}
+	tests := []struct {
+		name         string
+		source, dest []FileMetadata
+		want         []string
+	}{
+		{"vazios", nil, nil, nil},
+		{"só origem", source[:2], nil, []string{"missing_in_dest:a", "missing_in_dest:b"}},
+		{"só destino", nil, dest[:1], []string{"only_in_dest:0"}},
+		{"intercalados", source, dest, []string{
+			"missing_in_dest:a", "missing_in_dest:g",
+			"different_in_dest:b",
+			"only_in_dest:0", "only_in_dest:d",
+			"metadata_changed:c",
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "comparison.json")
+			out, err := createComparisonReport(path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			nextSource, stopSource := pullSorted(sliceSeq(tt.source))
+			defer stopSource()
+			nextDest, stopDest := pullSorted(sliceSeq(tt.dest))
+			defer stopDest()
+			advanced := 0
+			err = mergeJoin(context.Background(), nextSource, nextDest, false, false, out, func(FileMetadata) { advanced++ })
+			if err != nil {
+				out.Abort()
+				t.Fatal(err)
+			}
+			if advanced != len(tt.source) {
+				t.Errorf("%d entradas da origem percorridas, esperado %d", advanced, len(tt.source))
+			}
+			if err := out.Close(ComparisonResult{}); err != nil {
+				t.Fatal(err)
+			}
+			var got []string
+			err = readComparisonEntries(path, comparisonLists[:], func(list string, f FileMetadata) error {
+				got = append(got, list+":"+f.Path)
+				return nil
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("diferenças = %q, esperado %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestMergeJoinCanceled(t *testing.T) {
+	out, err := createComparisonReport(filepath.Join(t.TempDir(), "comparison.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer out.Abort()
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	nextSource, stop := pullSorted(sliceSeq(metadataOf("a")))
+	defer stop()
+	nextDest, stopDest := pullSorted(sliceSeq(nil))
+	defer stopDest()
+	if err := mergeJoin(ctx, nextSource, nextDest, false, false, out, func(FileMetadata) {}); err != context.Canceled {
+		t.Errorf("erro = %v, esperado %v", err, context.Canceled)
+	}
+}
+
+// followTree cria raiz/dados com arquivos, links para diretórios (inclusive
+// ciclos) e links para fora de dados, que é o único diretório permitido
+// durante o teste. Retorna o caminho de dados.
+func followTree(t *testing.T) string {
+	t.Helper()
+	base, err := filepath.EvalSymlinks(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	root := filepath.Join(base, "dados")
+	for _, dir := range []string{"dados/sub", "fora"} {
+		if err := os.MkdirAll(filepath.Join(base, dir), 0o755); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for _, name := range []string{"dados/a", "dados/sub/b", "fora/x"} {
+		if err := os.WriteFile(filepath.Join(base, name), []byte(name), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	links := [][2]string{
+		{"dados/ciclo", "."},
+		{"dados/sub/volta", ".."},
+		{"dados/interno", "sub"},
+		{"dados/dentro", "a"},
+		{"dados/fora", filepath.Join(base, "fora")},
+		{"dados/arq", filepath.Join(base, "fora", "x")},
+		{"dados/quebrado", "nada"},
+	}
+	for _, l := range links {
+		if err := os.Symlink(l[1], filepath.Join(base, l[0])); err != nil {
+			t.Skipf("links simbólicos indisponíveis: %v", err)
+		}
+	}
+
+	saved := sandbox
+	sandbox = &PathSandbox{roots: []string{root}}
+	t.Cleanup(func() { sandbox = saved })
+	return root
+}
+
+func TestWalkIncludedSymlinks(t *testing.T) {
+	root := followTree(t)
+	tests := []struct {
+		policy string
+		want   []string // caminho relativo, com "@" para entradas entregues como link
+	}{
+		{"skip", []string{"a", "sub/b"}},
+		{"link", []string{"a", "@arq", "@ciclo", "@dentro", "@fora", "@interno", "@quebrado", "sub/b", "@sub/volta"}},
+		{"follow", []string{
+			"a",
+			"@arq",   // arquivo fora dos diretórios permitidos
+			"@ciclo", // aponta para um ancestral
+			"dentro", // arquivo dentro de dados: seguido
+			"@fora",  // diretório fora dos diretórios permitidos
+			"interno/b",
+			"@interno/volta",
+			"@quebrado",
+			"sub/b",
+			"@sub/volta",
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.policy, func(t *testing.T) {
+			var got []string
+			err := walkIncluded(root, SyncOptions{SymlinkPolicy: tt.policy}, func(path string, info os.FileInfo) error {
+				rel, _ := filepath.Rel(root, path)
+				if info.Mode()&os.ModeSymlink != 0 {
+					rel = "@" + rel
+				}
+				got = append(got, filepath.ToSlash(rel))
+				return nil
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("entradas = %q, esperado %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCollectFileFollow(t *testing.T) {
+	root := followTree(t)
+	saved := hub
+	hub = newHub()
+	t.Cleanup(func() { hub = saved })
+
+	opts := SyncOptions{SymlinkPolicy: "follow", HashMode: "none"}
+	tests := []struct {
+		name string
+		link bool
+		warn string
+	}{
+		{"dentro", false, ""},
+		{"arq", true, "log.collect.symlink_outside"},
+		{"ciclo", true, "log.collect.symlink_cycle"},
+		{"quebrado", true, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			hub.pendingLogs = nil
+			meta, err := collectFile(context.Background(), root, filepath.Join(root, tt.name), opts)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if (meta.LinkTarget != "") != tt.link {
+				t.Errorf("LinkTarget = %q, esperado link: %v", meta.LinkTarget, tt.link)
+			}
+			if !tt.link && meta.Size != int64(len("dados/a")) {
+				t.Errorf("Size = %d, esperado o tamanho do arquivo apontado", meta.Size)
+			}
+			var warns []string
+			for _, ev := range hub.pendingLogs {
+				warns = append(warns, ev.Key)
+			}
+			if want := []string{tt.warn}; tt.warn != "" && !reflect.DeepEqual(warns, want) || tt.warn == "" && len(warns) > 0 {
+				t.Errorf("avisos = %q, esperado %q", warns, tt.warn)
+			}
+		})
+	}
+}
+
+// wsPair abre uma conexão WebSocket local e retorna as duas pontas: a do
+// servidor, entregue ao Hub, e a do navegador.
+func wsPair(t *testing.T) (server, client *websocket.Conn) {
+	t.Helper()
+	conns := make(chan *websocket.Conn, 1)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		conn, err := (&websocket.Upgrader{}).Upgrade(w, r, nil)
+		if err != nil {
+			t.Error(err)
+			return
+		}
+		conns <- conn
+	}))
+	t.Cleanup(srv.Close)
+	client, _, err := websocket.DefaultDialer.Dial("ws"+strings.TrimPrefix(srv.URL, "http"), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	server = <-conns
+	t.Cleanup(func() { client.Close(); server.Close() })
+	return server, client
+}
+
+func TestHubDropsSlowClient(t *testing.T) {
+	h := newHub()
+	// Sem writePump, as filas só esvaziam quando o teste as lê.
+	slowConn, _ := wsPair(t)
+	fastConn, _ := wsPair(t)
+	slow := &wsClient{conn: slowConn, send: make(chan []byte, 2)}
+	fast := &wsClient{conn: fastConn, send: make(chan []byte, clientQueueSize)}
+	h.clients[slow], h.clients[fast] = true, true
+
+	for i := range 3 {
+		h.broadcast(WSMessage{Type: "progress", Processed: int64(i)})
+	}
+
+	if h.ClientCount() != 1 || !h.clients[fast] {
+		t.Fatalf("%d clientes conectados, esperado apenas o rápido", h.ClientCount())
+	}
+	received := 0
+	for range slow.send {
+		received++
+	}
+	if received != 2 {
+		t.Errorf("cliente lento recebeu %d mensagens antes de ser desconectado, esperado 2", received)
+	}
+	if len(fast.send) != 3 {
+		t.Errorf("fila do cliente rápido com %d mensagens, esperado 3", len(fast.send))
+	}
+}
+
+func TestHubRegisterAndFlush(t *testing.T) {
+	h := newHub()
+	h.history = []LogEvent{{Level: LevelInfo, Message: "antes da conexão"}}
+	serverConn, clientConn := wsPair(t)
+	c := h.register(serverConn)
+	defer h.unregister(c)
+
+	// Excesso de logs entre dois envios: os mais antigos são descartados e
+	// um aviso informa quantos.
+	for range maxPendingLogs + 5 {
+		h.Log(LogEvent{Level: LevelInfo, Message: "linha"})
+	}
+	h.flush()
+
+	var types []string
+	var batch []LogEvent
+	clientConn.SetReadDeadline(time.Now().Add(5 * time.Second))
+	for range 3 {
+		_, data, err := clientConn.ReadMessage()
+		if err != nil {
+			t.Fatal(err)
+		}
+		var msg WSMessage
+		if err := json.Unmarshal(data, &msg); err != nil {
+			t.Fatal(err)
+		}
+		types = append(types, msg.Type)
+		if len(types) == 1 && (len(msg.Events) != 1 || msg.Events[0].Message != "antes da conexão") {
+			t.Errorf("histórico = %+v, esperado a linha anterior à conexão", msg.Events)
+		}
+		if len(types) == 3 {
+			batch = msg.Events
+		}
+	}
+	if want := []string{"logs", "progress", "logs"}; !reflect.DeepEqual(types, want) {
+		t.Fatalf("mensagens = %q, esperado %q", types, want)
+	}
+	if len(batch) != maxPendingLogs+1 || batch[0].Key != "log.dropped" || batch[0].Params["count"] != float64(5) {
+		t.Errorf("lote com %d eventos, primeiro %+v; esperado o aviso de 5 descartados e %d linhas", len(batch), batch[0], maxPendingLogs)
+	}
+	if len(h.history) != logHistorySize {
+		t.Errorf("histórico com %d linhas, esperado %d", len(h.history), logHistorySize)
+	}
+}
+
+func TestCopyEntryPreserve(t *testing.T) {
+	base, err := filepath.EvalSymlinks(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	saved := sandbox
+	sandbox = &PathSandbox{roots: []string{base}}
+	t.Cleanup(func() { sandbox = saved })
+
+	src := filepath.Join(base, "origem", "arquivo")
+	if err := os.MkdirAll(filepath.Dir(src), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(src, []byte("conteúdo"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chmod(src, 0o751); err != nil {
+		t.Fatal(err)
+	}
+	mtime := time.Date(2024, 5, 1, 8, 30, 0, 0, time.UTC)
+	atime := time.Date(2024, 6, 1, 9, 0, 0, 0, time.UTC)
+	xattrs := xattrsSupported && writeXattrs(src, map[string][]byte{"user.teste": []byte("valor")}) == nil
+	if !xattrs {
+		t.Log("atributos estendidos indisponíveis; verificação ignorada")
+	}
+	// Executando como root, a origem muda de dono para que a preservação seja visível.
+	root := os.Geteuid() == 0
+	if root {
+		if err := os.Lchown(src, 1234, 1234); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	tests := []struct {
+		name     string
+		preserve PreserveOptions
+	}{
+		{"padrão", PreserveOptions{}},
+		{"tudo", PreserveOptions{Mode: true, Owner: true, Atime: true, Xattrs: true}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// A leitura da cópia anterior atualizou a data de acesso da origem.
+			if err := os.Chtimes(src, atime, mtime); err != nil {
+				t.Fatal(err)
+			}
+			destRoot := filepath.Join(base, "destino-"+tt.name)
+			dst := filepath.Join(destRoot, "sub", "arquivo")
+			if err := copyEntry(context.Background(), src, destRoot, dst, FileMetadata{}, tt.preserve); err != nil {
+				t.Fatal(err)
+			}
+			info, err := os.Stat(dst)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if data, _ := os.ReadFile(dst); string(data) != "conteúdo" {
+				t.Errorf("conteúdo = %q", data)
+			}
+			// A data de modificação é sempre preservada.
+			if !info.ModTime().Equal(mtime) {
+				t.Errorf("ModTime = %v, esperado %v", info.ModTime(), mtime)
+			}
+			if got := info.Mode().Perm(); tt.preserve.Mode && got != 0o751 || !tt.preserve.Mode && got&0o111 != 0 {
+				t.Errorf("permissões = %v (preservar: %v)", got, tt.preserve.Mode)
+			}
+			if got := fileAtime(info); !got.IsZero() && tt.preserve.Atime && !got.Equal(atime) {
+				t.Errorf("data de acesso = %v, esperado %v", got, atime)
+			}
+			if owner, _ := fileOwner(info); root && owner != nil && (owner.UID == 1234) != tt.preserve.Owner {
+				t.Errorf("dono = %+v (preservar: %v)", owner, tt.preserve.Owner)
+			}
+			if xattrs {
+				attrs, err := readXattrs(dst)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if got := string(attrs["user.teste"]); tt.preserve.Xattrs && got != "valor" || !tt.preserve.Xattrs && got != "" {
+					t.Errorf("user.teste = %q (preservar: %v)", got, tt.preserve.Xattrs)
+				}
+			}
+		})
+	}
+}
+
+// TestCopySymlinkPolicies coleta e copia a árvore de followTree com cada
+// política: links para fora dos diretórios permitidos nunca são seguidos.
+func TestCopySymlinkPolicies(t *testing.T) {
+	root := followTree(t)
+	base := filepath.Dir(root)
+	saved := hub
+	hub = newHub()
+	t.Cleanup(func() { hub = saved })
+	outside := filepath.Join(base, "fora")
+
+	tests := []struct {
+		policy string
+		want   map[string]string // caminho no destino -> "arquivo", destino do link ou "" (ausente)
+	}{
+		{"skip", map[string]string{"a": "arquivo", "dentro": "", "arq": "", "fora": ""}},
+		{"link", map[string]string{"a": "arquivo", "dentro": "a", "arq": filepath.Join(outside, "x"), "fora": outside}},
+		{"follow", map[string]string{"a": "arquivo", "dentro": "arquivo", "arq": filepath.Join(outside, "x"), "fora": outside, "interno/b": "arquivo"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.policy, func(t *testing.T) {
+			destRoot := filepath.Join(base, "destino-"+tt.policy)
+			sandbox.roots = []string{root, destRoot}
+			opts := SyncOptions{SymlinkPolicy: tt.policy, HashMode: "none"}
+			err := collectMetadata(context.Background(), root, opts, func(f FileMetadata) error {
+				return copyEntry(context.Background(), filepath.Join(root, f.Path), destRoot, filepath.Join(destRoot, f.Path), f, PreserveOptions{})
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+			for rel, want := range tt.want {
+				path := filepath.Join(destRoot, filepath.FromSlash(rel))
+				got := ""
+				if info, err := os.Lstat(path); err == nil {
+					got = "arquivo"
+					if info.Mode()&os.ModeSymlink != 0 {
+						got, _ = os.Readlink(path)
+					}
+				}
+				if got != want {
+					t.Errorf("%s no destino = %q, esperado %q", rel, got, want)
+				}
+			}
+			if _, err := os.Stat(filepath.Join(destRoot, "fora", "x")); tt.policy != "skip" && err != nil {
+				t.Errorf("link para fora não recriado: %v", err)
+			}
+		})
+	}
+}
+
+// TestCopyEntryOutsideRoots cobre um arquivo coletado através de um link que,
+// antes da cópia, passou a apontar para fora dos diretórios permitidos.
+func TestCopyEntryOutsideRoots(t *testing.T) {
+	root := followTree(t)
+	destRoot := filepath.Join(filepath.Dir(root), "destino")
+	sandbox.roots = []string{root, destRoot}
+	dst := filepath.Join(destRoot, "arq")
+	err := copyEntry(context.Background(), filepath.Join(root, "arq"), destRoot, dst, FileMetadata{Path: "arq", Size: 6}, PreserveOptions{})
+	if !errors.Is(err, errOutsideRoots) {
+		t.Errorf("erro = %v, esperado %v", err, errOutsideRoots)
+	}
+	if _, err := os.Lstat(dst); !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("destino criado: %v", err)
+	}
+}
@@ -0,0 +1,117 @@
+//go:build linux
+
+package main
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"syscall"
+	"time"
+)
+
+// xattrsSupported indica se a plataforma permite coletar atributos estendidos.
+const xattrsSupported = true
+
+// fileOwner retorna o dono do arquivo e, se ele tiver mais de um hard link,
+// a identidade "dispositivo:inode" compartilhada pelos links.
+func fileOwner(info os.FileInfo) (*FileOwner, string) {
+	st, ok := info.Sys().(*syscall.Stat_t)
+	if !ok {
+		return nil, ""
+	}
+	owner := &FileOwner{UID: int(st.Uid), GID: int(st.Gid)}
+	if st.Nlink > 1 && !info.IsDir() {
+		return owner, fmt.Sprintf("%d:%d", st.Dev, st.Ino)
+	}
+	return owner, ""
+}
+
+// fileAtime retorna a data de acesso do arquivo.
+func fileAtime(info os.FileInfo) time.Time {
+	st, ok := info.Sys().(*syscall.Stat_t)
+	if !ok {
+		return time.Time{}
+	}
+	return time.Unix(int64(st.Atim.Sec), int64(st.Atim.Nsec))
+}
+
+// readXattrs lê os atributos estendidos de path. Sistemas de arquivos sem
+// suporte a eles resultam num mapa vazio.
+func readXattrs(path string) (map[string][]byte, error) {
+	names, err := xattrCall(func(buf []byte) (int, error) { return syscall.Listxattr(path, buf) })
+	if err != nil {
+		if errors.Is(err, syscall.ENOTSUP) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	var attrs map[string][]byte
+	for _, name := range bytes.Split(names, []byte{0}) {
+		if len(name) == 0 {
+			continue
+		}
+		value, err := xattrCall(func(buf []byte) (int, error) { return syscall.Getxattr(path, string(name), buf) })
+		if errors.Is(err, syscall.ENODATA) {
+			continue // removido entre a listagem e a leitura
+		}
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", name, err)
+		}
+		if attrs == nil {
+			attrs = make(map[string][]byte)
+		}
+		attrs[string(name)] = value
+	}
+	return attrs, nil
+}
+
+// writeXattrs substitui os atributos estendidos de path por attrs. As ACLs
+// POSIX são atributos system.posix_acl_* e são copiadas como os demais. Os
+// atributos security.* (ex.: rótulos do SELinux) ausentes em attrs são
+// mantidos, pois são definidos pela política do sistema no destino.
+func writeXattrs(path string, attrs map[string][]byte) error {
+	current, err := readXattrs(path)
+	if err != nil {
+		return err
+	}
+	for name := range current {
+		if _, ok := attrs[name]; ok || strings.HasPrefix(name, "security.") {
+			continue
+		}
+		if err := syscall.Removexattr(path, name); err != nil {
+			return fmt.Errorf("%s: %w", name, err)
+		}
+	}
+	for name, value := range attrs {
+		if old, ok := current[name]; ok && bytes.Equal(old, value) {
+			continue
+		}
+		if err := syscall.Setxattr(path, name, value, 0); err != nil {
+			return fmt.Errorf("%s: %w", name, err)
+		}
+	}
+	return nil
+}
+
+// xattrCall chama fn primeiro sem buffer, para obter o tamanho, e de novo
+// com um buffer desse tamanho, repetindo se o valor crescer no intervalo.
+func xattrCall(fn func([]byte) (int, error)) ([]byte, error) {
+	for {
+		size, err := fn(nil)
+		if err != nil || size == 0 {
+			return nil, err
+		}
+		buf := make([]byte, size)
+		n, err := fn(buf)
+		if errors.Is(err, syscall.ERANGE) {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		return buf[:n], nil
+	}
+}
@@ -0,0 +1,29 @@
+//go:build !linux
+
+package main
+
+import (
+	"os"
+	"time"
+)
+
+// xattrsSupported indica se a plataforma permite coletar atributos estendidos.
+const xattrsSupported = false
+
+// fileOwner não é suportado fora do Linux: os relatórios não trazem dono nem hard links.
+func fileOwner(info os.FileInfo) (*FileOwner, string) {
+	return nil, ""
+}
+
+func readXattrs(path string) (map[string][]byte, error) {
+	return nil, nil
+}
+
+func writeXattrs(path string, attrs map[string][]byte) error {
+	return nil
+}
+
+// fileAtime não é suportado fora do Linux; a data de acesso recebe a de modificação.
+func fileAtime(info os.FileInfo) time.Time {
+	return time.Time{}
+}
@@ -0,0 +1,167 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"net/http"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+//================================================================//
+// MÉTRICAS (FORMATO DE EXPOSIÇÃO DO PROMETHEUS)
+//================================================================//
+
+// jobDurationBuckets são os limites, em segundos, do histograma de duração das operações.
+var jobDurationBuckets = []float64{1, 5, 15, 60, 300, 900, 3600, 4 * 3600, 12 * 3600}
+
+// jobStates são os estados possíveis do StateManager expostos em gosync_job_state.
+var jobStates = []string{"idle", "running", "paused", "canceled", "finished"}
+
+type durationHistogram struct {
+	counts []uint64 // um contador por limite; o +Inf é o total
+	sum    float64
+	count  uint64
+}
+
+// Metrics acumula os contadores desde o início do processo.
+type Metrics struct {
+	filesHashed  atomic.Int64
+	filesCopied  atomic.Int64
+	bytesRead    atomic.Int64
+	bytesWritten atomic.Int64
+
+	// Protegidos por mu.
+	mu          sync.Mutex
+	filesFailed map[string]int64              // código do erro -> total
+	jobs        map[[2]string]int64           // {tipo, resultado} -> total
+	durations   map[string]*durationHistogram // tipo -> histograma
+}
+
+var metrics = &Metrics{
+	filesFailed: make(map[string]int64),
+	jobs:        make(map[[2]string]int64),
+	durations:   make(map[string]*durationHistogram),
+}
+
+func (m *Metrics) FileFailed(code string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.filesFailed[code]++
+}
+
+// JobFinished registra o resultado ("finished", "failed" ou "canceled") e a duração de uma operação.
+func (m *Metrics) JobFinished(kind, result string, elapsed time.Duration) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.jobs[[2]string{kind, result}]++
+	h, ok := m.durations[kind]
+	if !ok {
+		h = &durationHistogram{counts: make([]uint64, len(jobDurationBuckets))}
+		m.durations[kind] = h
+	}
+	seconds := elapsed.Seconds()
+	for i, limit := range jobDurationBuckets {
+		if seconds <= limit {
+			h.counts[i]++
+		}
+	}
+	h.sum += seconds
+	h.count++
+}
+
+// writeText escreve todas as métricas no formato de texto do Prometheus.
+func (m *Metrics) writeText(w *bufio.Writer) {
+	header := func(name, kind, help string) {
+		fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s %s\n", name, help, name, kind)
+	}
+
+	header("gosync_files_hashed_total", "counter", "Arquivos cujo hash SHA-256 foi calculado.")
+	fmt.Fprintf(w, "gosync_files_hashed_total %d\n", m.filesHashed.Load())
+	header("gosync_files_copied_total", "counter", "Arquivos copiados para o destino.")
+	fmt.Fprintf(w, "gosync_files_copied_total %d\n", m.filesCopied.Load())
+	header("gosync_bytes_read_total", "counter", "Bytes lidos de arquivos (hash e cópia).")
+	fmt.Fprintf(w, "gosync_bytes_read_total %d\n", m.bytesRead.Load())
+	header("gosync_bytes_written_total", "counter", "Bytes gravados no destino.")
+	fmt.Fprintf(w, "gosync_bytes_written_total %d\n", m.bytesWritten.Load())
+
+	m.mu.Lock()
+	header("gosync_files_failed_total", "counter", "Falhas em arquivos individuais, por código de erro.")
+	for _, code := range sortedKeys(m.filesFailed) {
+		fmt.Fprintf(w, "gosync_files_failed_total{code=%q} %d\n", code, m.filesFailed[code])
+	}
+
+	header("gosync_jobs_total", "counter", "Operações encerradas, por tipo e resultado.")
+	jobKeys := make([][2]string, 0, len(m.jobs))
+	for k := range m.jobs {
+		jobKeys = append(jobKeys, k)
+	}
+	sort.Slice(jobKeys, func(i, j int) bool {
+		if jobKeys[i][0] != jobKeys[j][0] {
+			return jobKeys[i][0] < jobKeys[j][0]
+		}
+		return jobKeys[i][1] < jobKeys[j][1]
+	})
+	for _, k := range jobKeys {
+		fmt.Fprintf(w, "gosync_jobs_total{kind=%q,result=%q} %d\n", k[0], k[1], m.jobs[k])
+	}
+
+	header("gosync_job_duration_seconds", "histogram", "Duração das operações, por tipo.")
+	for _, kind := range sortedKeys(m.durations) {
+		h := m.durations[kind]
+		for i, limit := range jobDurationBuckets {
+			fmt.Fprintf(w, "gosync_job_duration_seconds_bucket{kind=%q,le=\"%g\"} %d\n", kind, limit, h.counts[i])
+		}
+		fmt.Fprintf(w, "gosync_job_duration_seconds_bucket{kind=%q,le=\"+Inf\"} %d\n", kind, h.count)
+		fmt.Fprintf(w, "gosync_job_duration_seconds_sum{kind=%q} %g\n", kind, h.sum)
+		fmt.Fprintf(w, "gosync_job_duration_seconds_count{kind=%q} %d\n", kind, h.count)
+	}
+	m.mu.Unlock()
+
+	header("gosync_websocket_clients", "gauge", "Clientes WebSocket conectados.")
+	fmt.Fprintf(w, "gosync_websocket_clients %d\n", hub.ClientCount())
+
+	status := state.Status()
+	if status == "" {
+		status = "idle"
+	}
+	header("gosync_job_state", "gauge", "Estado da operação atual (1 para o estado vigente).")
+	for _, s := range jobStates {
+		value := 0
+		if s == status {
+			value = 1
+		}
+		fmt.Fprintf(w, "gosync_job_state{state=%q} %d\n", s, value)
+	}
+
+	processed, total := state.GetProgress()
+	header("gosync_job_items_processed", "gauge", "Itens processados na etapa atual.")
+	fmt.Fprintf(w, "gosync_job_items_processed %d\n", processed)
+	header("gosync_job_items_total", "gauge", "Itens previstos na etapa atual.")
+	fmt.Fprintf(w, "gosync_job_items_total %d\n", total)
+	header("gosync_job_bytes_processed", "gauge", "Bytes processados na etapa atual.")
+	fmt.Fprintf(w, "gosync_job_bytes_processed %d\n", state.processedBytes.Load())
+	header("gosync_job_bytes_total", "gauge", "Bytes previstos na etapa atual.")
+	fmt.Fprintf(w, "gosync_job_bytes_total %d\n", state.totalBytes.Load())
+	header("gosync_job_errors", "gauge", "Erros da operação atual.")
+	fmt.Fprintf(w, "gosync_job_errors %d\n", state.errorCount.Load())
+}
+
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// handleMetrics expõe as métricas para coleta pelo Prometheus.
+func handleMetrics(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+	bw := bufio.NewWriter(w)
+	metrics.writeText(bw)
+	bw.Flush()
+}
@@ -0,0 +1,331 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+)
+
+//================================================================//
+// PERFIS DE SINCRONIZAÇÃO
+//================================================================//
+
+// CopyOptions controla o comportamento do copiador.
+type CopyOptions struct {
+	Overwrite   bool            `json:"overwrite"`    // copia também os arquivos diferentes no destino
+	Verify      bool            `json:"verify"`       // recalcula o hash do arquivo copiado
+	Preserve    PreserveOptions `json:"preserve"`     // metadados da origem aplicados ao destino
+	FixMetadata bool            `json:"fix_metadata"` // ajusta os metadados dos arquivos de mesmo conteúdo
+}
+
+// PreserveOptions escolhe os metadados copiados junto com o conteúdo. A data
+// de modificação é sempre preservada.
+type PreserveOptions struct {
+	Mode   bool `json:"mode"`   // permissões, incluindo setuid, setgid e sticky
+	Owner  bool `json:"owner"`  // uid e gid; só tem efeito executando como root
+	Atime  bool `json:"atime"`  // data de acesso
+	Xattrs bool `json:"xattrs"` // atributos estendidos, incluindo as ACLs POSIX (apenas Linux)
+}
+
+// SyncOptions reúne as opções de coleta, cópia e exclusão de uma sincronização.
+type SyncOptions struct {
+	Exclusions     []string         `json:"exclusions"`
+	HashMode       string           `json:"hash_mode"`      // "sha256" ou "none" (apenas tamanho e data)
+	Xattrs         bool             `json:"xattrs"`         // coleta também os atributos estendidos (apenas Linux)
+	SymlinkPolicy  string           `json:"symlink_policy"` // "link" (copia o próprio link), "skip" ou "follow"
+	Copy           CopyOptions      `json:"copy_options"`
+	DeletionPolicy string           `json:"deletion_policy"` // "keep", "delete" ou "trash"
+	Throttle       ThrottleSettings `json:"throttle"`
+}
+
+// Profile é um par origem/destino nomeado com suas opções de sincronização.
+type Profile struct {
+	Name       string `json:"name"`
+	SourcePath string `json:"source_path"`
+	DestPath   string `json:"dest_path"`
+	SyncOptions
+}
+
+// systemExclusions são arquivos temporários do sistema ignorados em toda coleta.
+var systemExclusions = []string{"Thumbs.db", ".DS_Store", "desktop.ini", trashDirName}
+
+// trashDirName é o diretório, dentro do destino, que recebe os arquivos
+// removidos pela política de exclusão "trash".
+const trashDirName = ".sync-trash"
+
+func defaultSyncOptions() SyncOptions {
+	return SyncOptions{
+		HashMode:       "sha256",
+		SymlinkPolicy:  "link",
+		Copy:           CopyOptions{Overwrite: true},
+		DeletionPolicy: "keep",
+	}
+}
+
+func (o *SyncOptions) validate() error {
+	if o.HashMode == "" {
+		o.HashMode = "sha256"
+	}
+	if o.DeletionPolicy == "" {
+		o.DeletionPolicy = "keep"
+	}
+	if o.SymlinkPolicy == "" {
+		o.SymlinkPolicy = "link"
+	}
+	switch o.HashMode {
+	case "sha256", "none":
+	default:
+		return validationError("hash_mode", "modo de hash inválido: %q", o.HashMode)
+	}
+	switch o.DeletionPolicy {
+	case "keep", "delete", "trash":
+	default:
+		return validationError("deletion_policy", "política de exclusão inválida: %q", o.DeletionPolicy)
+	}
+	switch o.SymlinkPolicy {
+	case "link", "skip", "follow":
+	default:
+		return validationError("symlink_policy", "política de links simbólicos inválida: %q", o.SymlinkPolicy)
+	}
+	if err := o.Throttle.validate(); err != nil {
+		return validationError("throttle", "%v", err)
+	}
+	for _, pattern := range o.Exclusions {
+		if _, err := path.Match(pattern, ""); err != nil {
+			return validationError("exclusions", "padrão de exclusão inválido %q: %v", pattern, err)
+		}
+	}
+	return nil
+}
+
+// isExcluded indica se o caminho relativo corresponde a algum padrão de exclusão,
+// comparando tanto o nome do arquivo quanto o caminho completo.
+func (o *SyncOptions) isExcluded(relPath string) bool {
+	relPath = filepath.ToSlash(relPath)
+	base := path.Base(relPath)
+	for _, patterns := range [][]string{systemExclusions, o.Exclusions} {
+		for _, pattern := range patterns {
+			if ok, _ := path.Match(pattern, base); ok {
+				return true
+			}
+			if ok, _ := path.Match(pattern, relPath); ok {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// ProfileStore mantém os perfis em memória e os persiste em disco.
+type ProfileStore struct {
+	mu       sync.Mutex
+	fileName string
+	profiles map[string]Profile
+}
+
+var profiles *ProfileStore
+
+func newProfileStore(fileName string) *ProfileStore {
+	return &ProfileStore{fileName: fileName, profiles: make(map[string]Profile)}
+}
+
+// Load lê os perfis salvos. Um arquivo inexistente não é considerado erro.
+func (ps *ProfileStore) Load() error {
+	var saved []Profile
+	if err := readJSONFile(ps.fileName, &saved); err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return nil
+		}
+		return err
+	}
+	ps.mu.Lock()
+	defer ps.mu.Unlock()
+	for _, p := range saved {
+		ps.profiles[p.Name] = p
+	}
+	return nil
+}
+
+func (ps *ProfileStore) saveLocked() error {
+	return writeJSONFile(ps.fileName, ps.listLocked())
+}
+
+func (ps *ProfileStore) listLocked() []Profile {
+	list := make([]Profile, 0, len(ps.profiles))
+	for _, p := range ps.profiles {
+		list = append(list, p)
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].Name < list[j].Name })
+	return list
+}
+
+func (ps *ProfileStore) List() []Profile {
+	ps.mu.Lock()
+	defer ps.mu.Unlock()
+	return ps.listLocked()
+}
+
+func (ps *ProfileStore) Get(name string) (Profile, bool) {
+	ps.mu.Lock()
+	defer ps.mu.Unlock()
+	p, ok := ps.profiles[name]
+	return p, ok
+}
+
+// Put cria ou substitui um perfil.
+func (ps *ProfileStore) Put(p Profile) (Profile, error) {
+	p.Name = strings.TrimSpace(p.Name)
+	if p.Name == "" || p.SourcePath == "" || p.DestPath == "" {
+		return p, validationError("name", "nome, origem e destino são obrigatórios")
+	}
+	if err := p.validate(); err != nil {
+		return p, err
+	}
+	if _, err := p.resolvePaths(); err != nil {
+		return p, err
+	}
+	ps.mu.Lock()
+	defer ps.mu.Unlock()
+	ps.profiles[p.Name] = p
+	return p, ps.saveLocked()
+}
+
+// Delete remove um perfil. Perfis ainda usados por agendamentos não são
+// removidos: o erro lista os agendamentos, que devem ser excluídos antes.
+func (ps *ProfileStore) Delete(name string) error {
+	ps.mu.Lock()
+	defer ps.mu.Unlock()
+	if _, ok := ps.profiles[name]; !ok {
+		return fmt.Errorf("perfil %q %w", name, errNotFound)
+	}
+	if used := scheduler.UsingProfile(name); len(used) > 0 {
+		return apiError(http.StatusConflict, ErrCodeProfileInUse,
+			fmt.Sprintf("O perfil %s é usado pelos agendamentos: %s.", name, strings.Join(used, ", ")))
+	}
+	delete(ps.profiles, name)
+	return ps.saveLocked()
+}
+
+// resolvePaths retorna o perfil com origem e destino canonicalizados, desde que
+// ambos estejam dentro dos diretórios permitidos.
+func (p Profile) resolvePaths() (Profile, error) {
+	var err error
+	if p.SourcePath, err = sandbox.Resolve(p.SourcePath); err != nil {
+		return p, fmt.Errorf("origem: %w", err)
+	}
+	if p.DestPath, err = sandbox.Resolve(p.DestPath); err != nil {
+		return p, fmt.Errorf("destino: %w", err)
+	}
+	return p, nil
+}
+
+// startProfileSync inicia a sincronização encadeada do perfil informado e
+// retorna o ID da operação e um canal que recebe seu resultado.
+func startProfileSync(name string) (string, chan error, error) {
+	p, ok := profiles.Get(name)
+	if !ok {
+		return "", nil, fmt.Errorf("perfil %q %w", name, errNotFound)
+	}
+	p, err := p.resolvePaths()
+	if err != nil {
+		return "", nil, err
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	jobID, ok := state.TryStart("sync", cancel)
+	if !ok {
+		cancel()
+		return "", nil, errOperationRunning
+	}
+
+	throttle.Configure(p.Throttle)
+	sendLog("log.sync.started", "profile", p.Name)
+	done := make(chan error, 1)
+	go func() {
+		done <- runOperation(ctx, func(ctx context.Context) error {
+			return SyncDirectories(ctx, p.SourcePath, p.DestPath, p.SyncOptions)
+		})
+	}()
+	return jobID, done, nil
+}
+
+var errOperationRunning = errors.New("uma operação já está em andamento")
+
+//================================================================//
+// HTTP
+//================================================================//
+
+func handleProfiles(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		w.Header().Set("Content-Type", "application/json")
+		if name := r.URL.Query().Get("name"); name != "" {
+			p, ok := profiles.Get(name)
+			if !ok {
+				writeError(w, fmt.Errorf("perfil %q %w", name, errNotFound))
+				return
+			}
+			json.NewEncoder(w).Encode(p)
+			return
+		}
+		json.NewEncoder(w).Encode(profiles.List())
+	case http.MethodPost, http.MethodPut:
+		var req Profile
+		if err := decodeJSON(w, r, &req); err != nil {
+			writeError(w, err)
+			return
+		}
+		saved, err := profiles.Put(req)
+		if err != nil {
+			writeError(w, err)
+			return
+		}
+		writeJSON(w, http.StatusOK, saved)
+	case http.MethodDelete:
+		if err := profiles.Delete(r.URL.Query().Get("name")); err != nil {
+			writeError(w, err)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
+
+func handleSync(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		Profile string `json:"profile"`
+	}
+	if err := decodeJSON(w, r, &req); err != nil {
+		writeError(w, err)
+		return
+	}
+	if req.Profile == "" {
+		writeError(w, validationError("profile", "profile é obrigatório"))
+		return
+	}
+
+	jobID, _, err := startProfileSync(req.Profile)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusAccepted, JobAccepted{JobID: jobID, Kind: "sync"})
+}
+
+// resolveProfileOptions retorna as opções do perfil informado ou as opções padrão.
+func resolveProfileOptions(name string) (SyncOptions, error) {
+	if name == "" {
+		return defaultSyncOptions(), nil
+	}
+	p, ok := profiles.Get(name)
+	if !ok {
+		return SyncOptions{}, fmt.Errorf("perfil %q %w", name, errNotFound)
+	}
+	return p.SyncOptions, nil
+}
@@ -0,0 +1,51 @@
+package main
+
+import (
+	"errors"
+	"net/http"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestProfileDeleteInUse(t *testing.T) {
+	dir := t.TempDir()
+	ps := newProfileStore(filepath.Join(dir, "profiles.json"))
+	for _, name := range []string{"usado", "livre"} {
+		ps.profiles[name] = Profile{Name: name}
+	}
+	saved := scheduler
+	scheduler = newScheduler(filepath.Join(dir, "schedules.json"))
+	t.Cleanup(func() { scheduler = saved })
+	for _, name := range []string{"noite", "manhã"} {
+		scheduler.entries[name] = &scheduleEntry{ScheduledSync: ScheduledSync{Name: name, Profile: "usado"}}
+	}
+
+	err := ps.Delete("usado")
+	var apiErr *APIError
+	if !errors.As(err, &apiErr) || apiErr.Status != http.StatusConflict || apiErr.Code != ErrCodeProfileInUse {
+		t.Fatalf("erro = %v, esperado conflito %s", err, ErrCodeProfileInUse)
+	}
+	if !strings.Contains(apiErr.Message, "manhã, noite") {
+		t.Errorf("mensagem %q não lista os agendamentos", apiErr.Message)
+	}
+	if _, ok := ps.Get("usado"); !ok {
+		t.Error("perfil usado por agendamentos foi removido")
+	}
+
+	if err := ps.Delete("livre"); err != nil {
+		t.Errorf("Delete(livre): %v", err)
+	}
+	if err := ps.Delete("inexistente"); !errors.Is(err, errNotFound) {
+		t.Errorf("erro = %v, esperado %v", err, errNotFound)
+	}
+
+	for _, name := range []string{"noite", "manhã"} {
+		if err := scheduler.Delete(name); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := ps.Delete("usado"); err != nil {
+		t.Errorf("Delete após excluir os agendamentos: %v", err)
+	}
+}
@@ -0,0 +1,724 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"iter"
+	"os"
+	"path"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+//================================================================//
+// RELATÓRIOS DE COLETA EM STREAMING
+//================================================================//
+
+// Os relatórios de coleta são gravados em JSONL (extensão .jsonl): a primeira
+// linha é o cabeçalho (CollectionHeader) e cada linha seguinte é um
+// FileMetadata. Assim, nem o coletor nem quem lê o relatório precisam manter
+// todos os arquivos em memória. Os relatórios antigos (.json, um único objeto
+// CollectionReport) continuam legíveis por openCollectionReport.
+//
+// Qualquer relatório pode estar compactado com gzip (sufixo .gz). A leitura
+// detecta a compactação pelo conteúdo, não pelo nome, e reportPath aceita o
+// nome sem o sufixo, para que referências antigas continuem válidas depois que
+// um relatório é compactado.
+
+// collectionFormat identifica o formato no cabeçalho dos relatórios JSONL.
+const collectionFormat = "collection-jsonl/1"
+
+// reportBufferSize é o tamanho dos buffers de leitura e escrita dos relatórios.
+const reportBufferSize = 256 << 10
+
+// CollectionHeader é a primeira linha de um relatório de coleta JSONL.
+type CollectionHeader struct {
+	Format    string    `json:"format,omitempty"`
+	Type      string    `json:"type"`
+	RootPath  string    `json:"root_path"`
+	HashMode  string    `json:"hash_mode,omitempty"`
+	Timestamp time.Time `json:"timestamp"`
+	// Sorted indica que as entradas estão em ordem de Path (ver extsort.go).
+	Sorted bool `json:"sorted,omitempty"`
+	// Xattrs indica que os atributos estendidos foram coletados; sem ele, a
+	// ausência de Xattrs numa entrada não significa que o arquivo não os tenha.
+	Xattrs bool `json:"xattrs,omitempty"`
+}
+
+// gzipMagic são os dois primeiros bytes de um arquivo gzip.
+var gzipMagic = []byte{0x1f, 0x8b}
+
+// reportConfig é a configuração dos relatórios, lida de config/server.json.
+var reportConfig = defaultReportConfig()
+
+// reportFileName acrescenta .gz ao nome se os relatórios novos forem compactados.
+func reportFileName(name string) string {
+	if reportConfig.Compress {
+		return name + ".gz"
+	}
+	return name
+}
+
+// reportFile lê um relatório, descompactando-o se necessário.
+type reportFile struct {
+	file *os.File
+	gz   *gzip.Reader
+	r    io.Reader
+}
+
+func openReportFile(path string) (*reportFile, error) {
+	file, err := os.Open(path)
+	if errors.Is(err, os.ErrNotExist) && !strings.HasSuffix(path, ".gz") {
+		// O relatório pode ter sido compactado depois que path foi resolvido.
+		file, err = os.Open(path + ".gz")
+	}
+	if err != nil {
+		return nil, err
+	}
+	f := &reportFile{file: file}
+	if err := f.rewind(); err != nil {
+		file.Close()
+		return nil, err
+	}
+	return f, nil
+}
+
+// rewind volta a leitura para o início do relatório.
+func (f *reportFile) rewind() error {
+	if _, err := f.file.Seek(0, io.SeekStart); err != nil {
+		return err
+	}
+	br := bufio.NewReaderSize(f.file, reportBufferSize)
+	if magic, _ := br.Peek(len(gzipMagic)); !bytes.Equal(magic, gzipMagic) {
+		f.r = br
+		return nil
+	}
+	var err error
+	if f.gz == nil {
+		f.gz, err = gzip.NewReader(br)
+	} else {
+		err = f.gz.Reset(br)
+	}
+	f.r = f.gz
+	return err
+}
+
+// isGzipFile informa se o arquivo aberto está compactado com gzip.
+func isGzipFile(file *os.File) bool {
+	magic := make([]byte, len(gzipMagic))
+	n, _ := file.ReadAt(magic, 0)
+	return bytes.Equal(magic[:n], gzipMagic)
+}
+
+func (f *reportFile) Read(p []byte) (int, error) {
+	return f.r.Read(p)
+}
+
+func (f *reportFile) Close() error {
+	if f.gz != nil {
+		f.gz.Close()
+	}
+	return f.file.Close()
+}
+
+// collectionWriter grava um relatório de coleta entrada por entrada.
+type collectionWriter struct {
+	file *os.File
+	gz   *gzip.Writer // nil se o relatório não for compactado
+	buf  *bufio.Writer
+	enc  *json.Encoder
+	path string
+}
+
+// createCollectionReport grava o cabeçalho em path+".tmp", compactando o
+// relatório se path terminar em .gz. O arquivo só recebe o nome definitivo em
+// Close, para que uma coleta interrompida nunca seja lida como um relatório completo.
+func createCollectionReport(path string, header CollectionHeader) (*collectionWriter, error) {
+	file, err := os.Create(path + ".tmp")
+	if err != nil {
+		return nil, err
+	}
+	w := &collectionWriter{file: file, path: path}
+	if strings.HasSuffix(path, ".gz") {
+		w.gz = gzip.NewWriter(file)
+		w.buf = bufio.NewWriterSize(w.gz, reportBufferSize)
+	} else {
+		w.buf = bufio.NewWriterSize(file, reportBufferSize)
+	}
+	w.enc = json.NewEncoder(w.buf)
+	header.Format = collectionFormat
+	if err := w.enc.Encode(header); err != nil {
+		w.Abort()
+		return nil, err
+	}
+	return w, nil
+}
+
+func (w *collectionWriter) Write(f FileMetadata) error {
+	return w.enc.Encode(f)
+}
+
+// Close descarrega o buffer e publica o relatório com o nome definitivo.
+func (w *collectionWriter) Close() error {
+	if err := w.buf.Flush(); err != nil {
+		w.Abort()
+		return err
+	}
+	if w.gz != nil {
+		if err := w.gz.Close(); err != nil {
+			w.Abort()
+			return err
+		}
+	}
+	if err := w.file.Close(); err != nil {
+		os.Remove(w.file.Name())
+		return err
+	}
+	return os.Rename(w.file.Name(), w.path)
+}
+
+// Abort descarta o relatório incompleto.
+func (w *collectionWriter) Abort() {
+	w.file.Close()
+	os.Remove(w.file.Name())
+}
+
+// collectionReader lê um relatório de coleta em qualquer um dos formatos.
+type collectionReader struct {
+	Header CollectionHeader
+	path   string
+	file   *reportFile
+	dec    *json.Decoder
+	legacy bool
+	done   bool  // não há entradas a ler
+	count  int64 // entradas contadas ao ler o cabeçalho de um relatório antigo
+}
+
+// openCollectionReport abre o relatório e lê seu cabeçalho, deixando a leitura
+// posicionada na primeira entrada.
+func openCollectionReport(path string) (*collectionReader, error) {
+	file, err := openReportFile(path)
+	if err != nil {
+		return nil, err
+	}
+	r := &collectionReader{path: path, file: file, dec: json.NewDecoder(file)}
+	if strings.HasSuffix(strings.TrimSuffix(path, ".gz"), ".jsonl") {
+		err = r.dec.Decode(&r.Header)
+		if err == nil && r.Header.Format != collectionFormat {
+			err = fmt.Errorf("formato desconhecido %q", r.Header.Format)
+		}
+	} else {
+		r.legacy = true
+		err = r.openLegacy()
+	}
+	if err != nil {
+		file.Close()
+		return nil, err
+	}
+	return r, nil
+}
+
+// openLegacy trata o formato antigo, em que "timestamp" vem depois de
+// "files": uma primeira passagem lê o cabeçalho e conta as entradas sem
+// guardá-las; a segunda posiciona a leitura no início do array "files".
+func (r *collectionReader) openLegacy() error {
+	fields := make(map[string]json.RawMessage)
+	err := walkJSONObject(r.dec, func(key string) error {
+		if key != "files" {
+			var value json.RawMessage
+			if err := r.dec.Decode(&value); err != nil {
+				return err
+			}
+			fields[key] = value
+			return nil
+		}
+		if ok, err := r.enterFiles(); err != nil || !ok {
+			return err
+		}
+		for r.dec.More() {
+			var skip json.RawMessage
+			if err := r.dec.Decode(&skip); err != nil {
+				return err
+			}
+			r.count++
+		}
+		_, err := r.dec.Token()
+		return err
+	})
+	if err != nil {
+		return err
+	}
+	raw, _ := json.Marshal(fields)
+	if err := json.Unmarshal(raw, &r.Header); err != nil {
+		return err
+	}
+
+	if err := r.file.rewind(); err != nil {
+		return err
+	}
+	r.dec = json.NewDecoder(r.file)
+	errFound := errors.New("files")
+	err = walkJSONObject(r.dec, func(key string) error {
+		if key == "files" {
+			return errFound
+		}
+		var skip json.RawMessage
+		return r.dec.Decode(&skip)
+	})
+	switch {
+	case err == nil:
+		r.done = true // relatório sem "files"
+		return nil
+	case err != errFound:
+		return err
+	}
+	ok, err := r.enterFiles()
+	r.done = !ok
+	return err
+}
+
+// walkJSONObject percorre as chaves do objeto de nível superior, chamando
+// field para que ele consuma o valor de cada uma. Um erro de field interrompe
+// a leitura e é retornado.
+func walkJSONObject(dec *json.Decoder, field func(key string) error) error {
+	if tok, err := dec.Token(); err != nil || tok != json.Delim('{') {
+		return errors.New("relatório inválido: esperado objeto JSON")
+	}
+	for dec.More() {
+		tok, err := dec.Token()
+		if err != nil {
+			return err
+		}
+		key, _ := tok.(string)
+		if err := field(key); err != nil {
+			return err
+		}
+	}
+	_, err := dec.Token()
+	return err
+}
+
+// enterFiles consome o início do array "files". Retorna false se o valor for null.
+func (r *collectionReader) enterFiles() (bool, error) {
+	tok, err := r.dec.Token()
+	if err != nil {
+		return false, err
+	}
+	if tok == nil {
+		return false, nil
+	}
+	if tok != json.Delim('[') {
+		return false, errors.New(`relatório antigo inválido: "files" não é um array`)
+	}
+	return true, nil
+}
+
+// Files percorre as entradas do relatório, uma de cada vez. A iteração para no
+// primeiro erro de leitura, que é entregue junto com uma entrada vazia.
+func (r *collectionReader) Files() iter.Seq2[FileMetadata, error] {
+	return func(yield func(FileMetadata, error) bool) {
+		for !r.done && r.dec.More() {
+			var f FileMetadata
+			if err := r.dec.Decode(&f); err != nil {
+				yield(FileMetadata{}, err)
+				return
+			}
+			if !yield(f, nil) {
+				return
+			}
+		}
+	}
+}
+
+// Count retorna o número de entradas do relatório sem decodificá-las.
+func (r *collectionReader) Count() (int64, error) {
+	if r.legacy {
+		return r.count, nil
+	}
+	file, err := openReportFile(r.path)
+	if err != nil {
+		return 0, err
+	}
+	defer file.Close()
+	var lines int64
+	buf := make([]byte, reportBufferSize)
+	for {
+		n, err := file.Read(buf)
+		lines += int64(bytes.Count(buf[:n], []byte{'\n'}))
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return 0, err
+		}
+	}
+	return max(lines-1, 0), nil // a primeira linha é o cabeçalho
+}
+
+func (r *collectionReader) Close() error {
+	return r.file.Close()
+}
+
+// readCollectionHeader retorna apenas o cabeçalho do relatório.
+func readCollectionHeader(path string) (CollectionHeader, error) {
+	r, err := openCollectionReport(path)
+	if err != nil {
+		return CollectionHeader{}, err
+	}
+	defer r.Close()
+	return r.Header, nil
+}
+
+//================================================================//
+// RELATÓRIOS DE COMPARAÇÃO
+//================================================================//
+
+// comparisonWriter grava um ComparisonResult à medida que as diferenças são
+// encontradas. Cada lista vai para um arquivo temporário e o relatório é
+// montado em Close, sem que as listas fiquem em memória; apenas o resumo por
+// diretório, bem menor, é mantido.
+type comparisonWriter struct {
+	path  string
+	lists [4]*spooledList // missing_in_dest, different_in_dest, only_in_dest, metadata_changed
+	dirs  map[string]*DirSummary
+}
+
+var comparisonLists = [4]string{"missing_in_dest", "different_in_dest", "only_in_dest", "metadata_changed"}
+
+func createComparisonReport(path string) (*comparisonWriter, error) {
+	w := &comparisonWriter{path: path, dirs: make(map[string]*DirSummary)}
+	for i := range w.lists {
+		l, err := newSpooledList(filepath.Dir(path), "comparison-*.tmp")
+		if err != nil {
+			w.Abort()
+			return nil, err
+		}
+		w.lists[i] = l
+	}
+	return w, nil
+}
+
+func (w *comparisonWriter) add(list int, f FileMetadata) error {
+	// O arquivo conta no diretório em que está e em todos os seus ancestrais.
+	for dir := path.Dir(filepath.ToSlash(f.Path)); ; dir = path.Dir(dir) {
+		s, ok := w.dirs[dir]
+		if !ok {
+			s = &DirSummary{Path: dir}
+			w.dirs[dir] = s
+		}
+		total := [4]*DiffTotal{&s.Missing, &s.Different, &s.OnlyInDest, &s.MetadataChanged}[list]
+		total.Files++
+		total.Bytes += f.Size
+		if dir == "." || dir == "/" {
+			break
+		}
+	}
+	return w.lists[list].add(f)
+}
+
+func (w *comparisonWriter) Missing(f FileMetadata) error    { return w.add(0, f) }
+func (w *comparisonWriter) Different(f FileMetadata) error  { return w.add(1, f) }
+func (w *comparisonWriter) OnlyInDest(f FileMetadata) error { return w.add(2, f) }
+
+// MetadataChanged registra um arquivo de mesmo conteúdo, mas com metadados diferentes.
+func (w *comparisonWriter) MetadataChanged(f FileMetadata) error { return w.add(3, f) }
+
+// Directories retorna o resumo por diretório, em ordem de caminho.
+func (w *comparisonWriter) Directories() []DirSummary {
+	dirs := make([]DirSummary, 0, len(w.dirs))
+	for _, s := range w.dirs {
+		dirs = append(dirs, *s)
+	}
+	sort.Slice(dirs, func(i, j int) bool { return dirs[i].Path < dirs[j].Path })
+	return dirs
+}
+
+// Counts retorna o tamanho das listas: ausentes, diferentes, exclusivos do
+// destino e com apenas os metadados diferentes.
+func (w *comparisonWriter) Counts() (missing, different, onlyInDest, metadataChanged int) {
+	return w.lists[0].count, w.lists[1].count, w.lists[2].count, w.lists[3].count
+}
+
+// Close grava o relatório com os campos de result (cujas listas e resumo são
+// ignorados) e os dados acumulados, compactando-o se path terminar em .gz.
+func (w *comparisonWriter) Close(result ComparisonResult) error {
+	defer w.Abort()
+	return writeReportFile(w.path, func(out *bufio.Writer) error {
+		return w.writeResult(out, result)
+	})
+}
+
+// writeResult escreve o objeto JSON na mesma ordem de campos de
+// ComparisonResult. Erros de escrita ficam retidos em out e aparecem no Flush.
+func (w *comparisonWriter) writeResult(out *bufio.Writer, result ComparisonResult) error {
+	header, err := json.Marshal(struct {
+		SourceReport      string `json:"source_report"`
+		DestinationReport string `json:"destination_report"`
+		SourceRoot        string `json:"source_root"`
+		DestinationRoot   string `json:"destination_root"`
+	}{result.SourceReport, result.DestinationReport, result.SourceRoot, result.DestinationRoot})
+	if err != nil {
+		return err
+	}
+	directories, err := json.Marshal(w.Directories())
+	if err != nil {
+		return err
+	}
+	timestamp, err := json.Marshal(result.Timestamp)
+	if err != nil {
+		return err
+	}
+	out.Write(bytes.TrimSuffix(header, []byte("}")))
+	for i, l := range w.lists {
+		fmt.Fprintf(out, ",%q:", comparisonLists[i])
+		if err := l.writeArray(out); err != nil {
+			return err
+		}
+	}
+	fmt.Fprintf(out, ",\"directories\":%s,\"timestamp\":%s}\n", directories, timestamp)
+	return nil
+}
+
+// Abort descarta as listas temporárias.
+func (w *comparisonWriter) Abort() {
+	for _, l := range w.lists {
+		l.remove()
+	}
+}
+
+// readComparisonEntries percorre o relatório de comparação em path sem
+// carregá-lo inteiro, chamando fn para cada entrada das listas indicadas em
+// lists (nomes de comparisonLists), na ordem do arquivo. As entradas das demais
+// listas são puladas uma a uma. Um erro de fn interrompe a leitura e é retornado.
+func readComparisonEntries(path string, lists []string, fn func(list string, f FileMetadata) error) error {
+	file, err := openReportFile(path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	dec := json.NewDecoder(file)
+	return walkJSONObject(dec, func(key string) error {
+		if !slices.Contains(comparisonLists[:], key) {
+			var skip json.RawMessage
+			return dec.Decode(&skip)
+		}
+		tok, err := dec.Token()
+		if err != nil || tok == nil {
+			return err
+		}
+		if tok != json.Delim('[') {
+			return fmt.Errorf("relatório inválido: %q não é um array", key)
+		}
+		wanted := slices.Contains(lists, key)
+		for dec.More() {
+			if !wanted {
+				var skip json.RawMessage
+				if err := dec.Decode(&skip); err != nil {
+					return err
+				}
+				continue
+			}
+			var f FileMetadata
+			if err := dec.Decode(&f); err != nil {
+				return err
+			}
+			if err := fn(key, f); err != nil {
+				return err
+			}
+		}
+		_, err = dec.Token()
+		return err
+	})
+}
+
+//================================================================//
+// RELATÓRIOS DE CÓPIA
+//================================================================//
+
+// copyWriter grava um CopyReport à medida que a cópia avança. Como no
+// comparisonWriter, cada lista vai para um arquivo temporário e o relatório é
+// montado em Close. Pode ser usado por vários workers ao mesmo tempo.
+type copyWriter struct {
+	mu               sync.Mutex
+	comparisonReport string
+	lists            [5]*spooledList // copied, failed, metadata_fixed, skipped, deleted
+}
+
+var copyLists = [5]string{"copied", "failed", "metadata_fixed", "skipped", "deleted"}
+
+func createCopyReport(comparisonReport string) (*copyWriter, error) {
+	w := &copyWriter{comparisonReport: comparisonReport}
+	for i := range w.lists {
+		l, err := newSpooledList(copyDir, "copy-*.tmp")
+		if err != nil {
+			w.Abort()
+			return nil, err
+		}
+		w.lists[i] = l
+	}
+	return w, nil
+}
+
+func (w *copyWriter) add(list int, v any) error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.lists[list].add(v)
+}
+
+func (w *copyWriter) Copied(f FileMetadata) error { return w.add(0, f) }
+
+func (w *copyWriter) Failed(path string, err error) error {
+	return w.add(1, CopyFailure{Path: path, Error: err.Error()})
+}
+
+func (w *copyWriter) MetadataFixed(path string) error { return w.add(2, path) }
+func (w *copyWriter) Skipped(path string) error       { return w.add(3, path) }
+func (w *copyWriter) Deleted(path string) error       { return w.add(4, path) }
+
+// Counts retorna o tamanho das listas: copiados, falhas, metadados ajustados,
+// ignorados e removidos.
+func (w *copyWriter) Counts() (copied, failed, metadataFixed, skipped, deleted int) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.lists[0].count, w.lists[1].count, w.lists[2].count, w.lists[3].count, w.lists[4].count
+}
+
+// Close grava o relatório em path, compactando-o se path terminar em .gz, na
+// mesma ordem de campos de CopyReport.
+func (w *copyWriter) Close(path string, timestamp time.Time) error {
+	defer w.Abort()
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return writeReportFile(path, func(out *bufio.Writer) error {
+		comparisonReport, err := json.Marshal(w.comparisonReport)
+		if err != nil {
+			return err
+		}
+		ts, err := json.Marshal(timestamp)
+		if err != nil {
+			return err
+		}
+		fmt.Fprintf(out, "{\"comparison_report\":%s", comparisonReport)
+		for i, l := range w.lists {
+			fmt.Fprintf(out, ",%q:", copyLists[i])
+			if err := l.writeArray(out); err != nil {
+				return err
+			}
+		}
+		fmt.Fprintf(out, ",\"timestamp\":%s}\n", ts)
+		return nil
+	})
+}
+
+// Abort descarta as listas temporárias.
+func (w *copyWriter) Abort() {
+	for _, l := range w.lists {
+		l.remove()
+	}
+}
+
+//================================================================//
+// LISTAS EM ARQUIVOS TEMPORÁRIOS
+//================================================================//
+
+// spooledList guarda os itens de uma lista de relatório em um arquivo
+// temporário, um JSON por linha, até que o relatório seja montado.
+type spooledList struct {
+	file  *os.File
+	buf   *bufio.Writer
+	enc   *json.Encoder
+	count int
+}
+
+func newSpooledList(dir, pattern string) (*spooledList, error) {
+	file, err := os.CreateTemp(dir, pattern)
+	if err != nil {
+		return nil, err
+	}
+	buf := bufio.NewWriterSize(file, reportBufferSize)
+	return &spooledList{file: file, buf: buf, enc: json.NewEncoder(buf)}, nil
+}
+
+func (l *spooledList) add(v any) error {
+	l.count++
+	return l.enc.Encode(v)
+}
+
+// writeArray escreve os itens guardados em out como um array JSON.
+func (l *spooledList) writeArray(out *bufio.Writer) error {
+	if err := l.buf.Flush(); err != nil {
+		return err
+	}
+	if _, err := l.file.Seek(0, io.SeekStart); err != nil {
+		return err
+	}
+	out.WriteByte('[')
+	in := bufio.NewReaderSize(l.file, reportBufferSize)
+	for first := true; ; first = false {
+		line, err := in.ReadBytes('\n')
+		if len(line) > 0 {
+			if !first {
+				out.WriteByte(',')
+			}
+			out.Write(bytes.TrimSuffix(line, []byte{'\n'}))
+		}
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return err
+		}
+	}
+	out.WriteByte(']')
+	return nil
+}
+
+// remove apaga o arquivo temporário. Pode ser chamado em uma lista nil.
+func (l *spooledList) remove() {
+	if l != nil {
+		l.file.Close()
+		os.Remove(l.file.Name())
+	}
+}
+
+// writeReportFile grava em path+".tmp" o que write escrever, compactando se
+// path terminar em .gz, e só então dá ao arquivo o nome definitivo.
+func writeReportFile(path string, write func(out *bufio.Writer) error) error {
+	out, err := os.Create(path + ".tmp")
+	if err != nil {
+		return err
+	}
+	var dst io.Writer = out
+	var gz *gzip.Writer
+	if strings.HasSuffix(path, ".gz") {
+		gz = gzip.NewWriter(out)
+		dst = gz
+	}
+	buf := bufio.NewWriterSize(dst, reportBufferSize)
+	err = write(buf)
+	if err == nil {
+		err = buf.Flush()
+	}
+	if err == nil && gz != nil {
+		err = gz.Close()
+	}
+	if cerr := out.Close(); err == nil {
+		err = cerr
+	}
+	if err == nil {
+		err = os.Rename(out.Name(), path)
+	}
+	if err != nil {
+		os.Remove(out.Name())
+	}
+	return err
+}
@@ -0,0 +1,346 @@
+package main
+
+import (
+	"compress/gzip"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestOpenCollectionReport(t *testing.T) {
+	header := `{"format":"collection-jsonl/1","type":"source","root_path":"/o","timestamp":"2025-01-01T00:00:00Z","sorted":true}`
+	tests := []struct {
+		name    string
+		file    string
+		content string
+		gzip    bool
+		root    string
+		paths   []string
+		wantErr bool
+	}{
+		{
+			name:    "jsonl",
+			file:    "source_1.jsonl",
+			content: header + "\n" + `{"path":"a","size":1}` + "\n" + `{"path":"b","size":2}` + "\n",
+			root:    "/o",
+			paths:   []string{"a", "b"},
+		},
+		{
+			name:    "jsonl compactado",
+			file:    "source_1.jsonl.gz",
+			content: header + "\n" + `{"path":"a"}` + "\n",
+			gzip:    true,
+			root:    "/o",
+			paths:   []string{"a"},
+		},
+		{
+			name:    "jsonl só com cabeçalho",
+			file:    "source_1.jsonl",
+			content: header + "\n",
+			root:    "/o",
+		},
+		{
+			name:    "jsonl de formato desconhecido",
+			file:    "source_1.jsonl",
+			content: `{"format":"collection-jsonl/9"}` + "\n",
+			wantErr: true,
+		},
+		{
+			name:    "antigo com timestamp depois de files",
+			file:    "source_1.json",
+			content: `{"type":"source","root_path":"/o","files":[{"path":"a","size":1},{"path":"b"}],"timestamp":"2025-01-01T00:00:00Z"}`,
+			root:    "/o",
+			paths:   []string{"a", "b"},
+		},
+		{
+			name:    "antigo compactado",
+			file:    "source_1.json",
+			content: `{"root_path":"/o","files":[{"path":"a"}]}`,
+			gzip:    true,
+			root:    "/o",
+			paths:   []string{"a"},
+		},
+		{
+			name:    "antigo com files nulo",
+			file:    "source_1.json",
+			content: `{"files":null,"root_path":"/o"}`,
+			root:    "/o",
+		},
+		{
+			name:    "antigo sem files",
+			file:    "source_1.json",
+			content: `{"root_path":"/o"}`,
+			root:    "/o",
+		},
+		{
+			name:    "antigo com files inválido",
+			file:    "source_1.json",
+			content: `{"root_path":"/o","files":{"path":"a"}}`,
+			wantErr: true,
+		},
+		{
+			name:    "não é objeto",
+			file:    "source_1.json",
+			content: `[]`,
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), tt.file)
+			file, err := os.Create(path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if tt.gzip {
+				zw := gzip.NewWriter(file)
+				zw.Write([]byte(tt.content))
+				zw.Close()
+			} else {
+				file.WriteString(tt.content)
+			}
+			if err := file.Close(); err != nil {
+				t.Fatal(err)
+			}
+
+			r, err := openCollectionReport(path)
+			if tt.wantErr {
+				if err == nil {
+					r.Close()
+					t.Fatal("relatório aceito, esperado erro")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer r.Close()
+			if r.Header.RootPath != tt.root {
+				t.Errorf("RootPath = %q, esperado %q", r.Header.RootPath, tt.root)
+			}
+			count, err := r.Count()
+			if err != nil || count != int64(len(tt.paths)) {
+				t.Errorf("Count = %d, %v; esperado %d", count, err, len(tt.paths))
+			}
+			var got []string
+			for f, err := range r.Files() {
+				if err != nil {
+					t.Fatal(err)
+				}
+				got = append(got, f.Path)
+			}
+			if !reflect.DeepEqual(got, tt.paths) {
+				t.Errorf("entradas = %q, esperado %q", got, tt.paths)
+			}
+		})
+	}
+}
+
+func TestReadComparisonEntries(t *testing.T) {
+	for _, name := range []string{"comparison.json", "comparison.json.gz"} {
+		t.Run(name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), name)
+			w, err := createComparisonReport(path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			w.Missing(FileMetadata{Path: "a", Size: 1})
+			w.Missing(FileMetadata{Path: "dir/b", Size: 2})
+			w.Different(FileMetadata{Path: "c"})
+			w.OnlyInDest(FileMetadata{Path: "d"})
+			if err := w.Close(ComparisonResult{SourceRoot: "/origem", DestinationRoot: "/destino"}); err != nil {
+				t.Fatal(err)
+			}
+
+			tests := []struct {
+				lists []string
+				want  []string
+			}{
+				{comparisonLists[:], []string{"missing_in_dest:a", "missing_in_dest:dir/b", "different_in_dest:c", "only_in_dest:d"}},
+				{[]string{"only_in_dest"}, []string{"only_in_dest:d"}},
+				{[]string{"metadata_changed"}, nil},
+				{nil, nil},
+			}
+			for _, tt := range tests {
+				var got []string
+				err := readComparisonEntries(path, tt.lists, func(list string, f FileMetadata) error {
+					got = append(got, list+":"+f.Path)
+					return nil
+				})
+				if err != nil {
+					t.Fatalf("readComparisonEntries(%q): %v", tt.lists, err)
+				}
+				if !reflect.DeepEqual(got, tt.want) {
+					t.Errorf("readComparisonEntries(%q) = %q, esperado %q", tt.lists, got, tt.want)
+				}
+			}
+
+			source, dest, err := readComparisonRoots(path)
+			if err != nil || source != "/origem" || dest != "/destino" {
+				t.Errorf("readComparisonRoots = %q, %q, %v", source, dest, err)
+			}
+		})
+	}
+}
+
+func TestComparisonWriterDirectories(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "comparison.json")
+	w, err := createComparisonReport(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Uma árvore inteira ausente no destino (a/b/c) sob diretórios que também
+	// têm outras diferenças.
+	entries := []struct {
+		add  func(FileMetadata) error
+		path string
+		size int64
+	}{
+		{w.Missing, "a/b/c/1", 10},
+		{w.Missing, "a/b/c/d/2", 5},
+		{w.Different, "a/b/3", 3},
+		{w.MetadataChanged, "a/4", 1},
+		{w.OnlyInDest, "5", 7},
+	}
+	for _, e := range entries {
+		if err := e.add(FileMetadata{Path: filepath.FromSlash(e.path), Size: e.size}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	want := []DirSummary{
+		{Path: ".", Missing: DiffTotal{2, 15}, Different: DiffTotal{1, 3}, OnlyInDest: DiffTotal{1, 7}, MetadataChanged: DiffTotal{1, 1}},
+		{Path: "a", Missing: DiffTotal{2, 15}, Different: DiffTotal{1, 3}, MetadataChanged: DiffTotal{1, 1}},
+		{Path: "a/b", Missing: DiffTotal{2, 15}, Different: DiffTotal{1, 3}},
+		{Path: "a/b/c", Missing: DiffTotal{2, 15}},
+		{Path: "a/b/c/d", Missing: DiffTotal{1, 5}},
+	}
+	if got := w.Directories(); !reflect.DeepEqual(got, want) {
+		t.Errorf("Directories = %+v, esperado %+v", got, want)
+	}
+	if missing, different, onlyInDest, metadataChanged := w.Counts(); missing != 2 || different != 1 || onlyInDest != 1 || metadataChanged != 1 {
+		t.Errorf("Counts = %d, %d, %d, %d; esperado 2, 1, 1, 1", missing, different, onlyInDest, metadataChanged)
+	}
+
+	if err := w.Close(ComparisonResult{}); err != nil {
+		t.Fatal(err)
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var result ComparisonResult
+	if err := json.Unmarshal(data, &result); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(result.Directories, want) {
+		t.Errorf("diretórios gravados = %+v, esperado %+v", result.Directories, want)
+	}
+}
+
+// TestReadComparisonEntriesLegacy lê um relatório gravado de uma vez, como nas
+// versões anteriores, com listas nulas e campos em outra ordem.
+func TestReadComparisonEntriesLegacy(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "comparison.json")
+	legacy := `{"timestamp":"2025-01-01T00:00:00Z","missing_in_dest":null,` +
+		`"different_in_dest":[{"path":"x","size":3}],"only_in_dest":[],"source_root":"/o","destination_root":"/d"}`
+	if err := os.WriteFile(path, []byte(legacy), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	var got []string
+	err := readComparisonEntries(path, comparisonLists[:], func(list string, f FileMetadata) error {
+		got = append(got, list+":"+f.Path)
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{"different_in_dest:x"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("entradas = %q, esperado %q", got, want)
+	}
+}
+
+func TestReadComparisonEntriesStops(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "comparison.json")
+	w, err := createComparisonReport(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	w.Missing(FileMetadata{Path: "a"})
+	w.Missing(FileMetadata{Path: "b"})
+	if err := w.Close(ComparisonResult{}); err != nil {
+		t.Fatal(err)
+	}
+	errStop := errors.New("parar")
+	calls := 0
+	err = readComparisonEntries(path, comparisonLists[:], func(string, FileMetadata) error {
+		calls++
+		return errStop
+	})
+	if !errors.Is(err, errStop) || calls != 1 {
+		t.Errorf("erro = %v após %d chamadas; esperado %v após 1", err, calls, errStop)
+	}
+}
+
+func TestCopyWriter(t *testing.T) {
+	for _, name := range []string{"copy.json", "copy.json.gz"} {
+		t.Run(name, func(t *testing.T) {
+			t.Chdir(t.TempDir())
+			if err := os.Mkdir(copyDir, 0o755); err != nil {
+				t.Fatal(err)
+			}
+			w, err := createCopyReport("comparison.json")
+			if err != nil {
+				t.Fatal(err)
+			}
+			w.Copied(FileMetadata{Path: "a", Size: 1})
+			w.Copied(FileMetadata{Path: "b", Size: 2})
+			w.Failed("c", errors.New("sem espaço"))
+			w.MetadataFixed("d")
+			w.Deleted("e")
+			timestamp := time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC)
+			path := filepath.Join(copyDir, name)
+			if err := w.Close(path, timestamp); err != nil {
+				t.Fatal(err)
+			}
+
+			file, err := openReportFile(path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer file.Close()
+			var got CopyReport
+			if err := json.NewDecoder(file).Decode(&got); err != nil {
+				t.Fatal(err)
+			}
+			want := CopyReport{
+				ComparisonReport: "comparison.json",
+				Copied:           []FileMetadata{{Path: "a", Size: 1}, {Path: "b", Size: 2}},
+				Failed:           []CopyFailure{{Path: "c", Error: "sem espaço"}},
+				MetadataFixed:    []string{"d"},
+				Skipped:          []string{},
+				Deleted:          []string{"e"},
+				Timestamp:        timestamp,
+			}
+			if !reflect.DeepEqual(got, want) {
+				t.Errorf("relatório = %+v, esperado %+v", got, want)
+			}
+			if copied, failed, _, skipped, _ := w.Counts(); copied != 2 || failed != 1 || skipped != 0 {
+				t.Errorf("Counts = %d copiados, %d falhas, %d ignorados", copied, failed, skipped)
+			}
+
+			// Só o relatório fica no diretório: as listas temporárias são removidas.
+			entries, _ := os.ReadDir(copyDir)
+			if len(entries) != 1 || entries[0].Name() != name {
+				t.Errorf("arquivos em %s: %v", copyDir, entries)
+			}
+			if raw, _ := os.ReadFile(path); strings.HasSuffix(name, ".gz") != (len(raw) > 1 && raw[0] == 0x1f) {
+				t.Errorf("compactação de %s não corresponde ao nome", name)
+			}
+		})
+	}
+}
@@ -0,0 +1,390 @@
+package main
+
+import (
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+//================================================================//
+// RETENÇÃO E MANUTENÇÃO DOS RELATÓRIOS
+//================================================================//
+
+// A limpeza dos relatórios é uma operação como as demais ("cleanup"): aparece
+// no histórico, tem log próprio e não roda junto com outra operação. Ela é
+// iniciada pela API ou, a cada reportMaintenanceInterval, automaticamente se
+// houver relatórios a remover ou compactar e o servidor estiver livre. Durante
+// um monitoramento, que pode durar dias, a limpeza automática roda entre dois
+// ciclos dele e fica registrada no seu log.
+
+// reportMaintenanceInterval é o intervalo entre as verificações dos relatórios.
+const reportMaintenanceInterval = time.Hour
+
+// cleanupAction é a remoção (reason preenchido) ou a compactação de um relatório.
+type cleanupAction struct {
+	report ReportInfo
+	path   string
+	reason Text
+}
+
+// runReportMaintenance verifica periodicamente os relatórios, iniciando a
+// limpeza quando há algo a fazer. A primeira verificação acontece na inicialização.
+func runReportMaintenance() {
+	ticker := time.NewTicker(reportMaintenanceInterval)
+	defer ticker.Stop()
+	for {
+		if actions, _ := planCleanup(time.Now()); len(actions) > 0 {
+			// Com outra operação em andamento, a limpeza fica para a próxima vez.
+			_, err := startCleanup()
+			if errors.Is(err, errOperationRunning) {
+				requestWatchMaintenance()
+			} else if err != nil {
+				log.Printf("Falha ao iniciar a limpeza dos relatórios: %v", err)
+			}
+		}
+		<-ticker.C
+	}
+}
+
+// startCleanup inicia a limpeza dos relatórios em segundo plano.
+func startCleanup() (string, error) {
+	ctx, cancel := context.WithCancel(context.Background())
+	jobID, ok := state.TryStart("cleanup", cancel)
+	if !ok {
+		cancel()
+		return "", errOperationRunning
+	}
+	go runOperation(ctx, CleanupReports)
+	return jobID, nil
+}
+
+// planCleanup aplica as regras de reportConfig aos relatórios salvos. Retorna
+// as ações a executar e os relatórios que seriam removidos, mas estão em uso.
+func planCleanup(now time.Time) ([]cleanupAction, []cleanupAction) {
+	cfg := reportConfig
+	if !cfg.retains() && cfg.CompressAfterDays <= 0 {
+		return nil, nil
+	}
+	reports := listReports(reportKinds...)
+	if cfg.KeepLast > 0 {
+		defer pruneReportGroups(reports)
+	}
+	return planReports(cfg, reports, jobHistory.ReportsInUse(), now, cachedReportGroup)
+}
+
+// planReports aplica as regras de cfg a reports, ordenados do mais recente para
+// o mais antigo. inUse são os relatórios (sem .gz) que não podem ser removidos
+// e groupOf identifica o grupo de cada relatório para a regra keep_last.
+func planReports(cfg ReportConfig, reports []ReportInfo, inUse map[string]string, now time.Time,
+	groupOf func(ReportInfo) string) ([]cleanupAction, []cleanupAction) {
+	reasons := make([]Text, len(reports))
+
+	if cfg.KeepLast > 0 {
+		kept := make(map[string]int)
+		for i, rep := range reports {
+			group := groupOf(rep)
+			if kept[rep.Kind+"\x00"+group] < cfg.KeepLast {
+				kept[rep.Kind+"\x00"+group]++
+				continue
+			}
+			reasons[i] = T("log.cleanup.reason.keep_last", "count", cfg.KeepLast, "group", group)
+		}
+	}
+	if cfg.MaxAgeDays > 0 {
+		cutoff := now.AddDate(0, 0, -cfg.MaxAgeDays)
+		for i, rep := range reports {
+			if reasons[i].Key == "" && rep.ModifiedAt.Before(cutoff) {
+				reasons[i] = T("log.cleanup.reason.max_age", "days", cfg.MaxAgeDays)
+			}
+		}
+	}
+
+	if cfg.MaxTotalMB > 0 {
+		// Os relatórios mais recentes e os em uso ocupam o espaço primeiro.
+		var total int64
+		for i, rep := range reports {
+			_, used := inUse[strings.TrimSuffix(rep.Name, ".gz")]
+			if reasons[i].Key != "" && !used {
+				continue
+			}
+			total += rep.Size
+		}
+		limit := cfg.MaxTotalMB << 20
+		for i := len(reports) - 1; i >= 0 && total > limit; i-- {
+			if _, used := inUse[strings.TrimSuffix(reports[i].Name, ".gz")]; used || reasons[i].Key != "" {
+				continue
+			}
+			reasons[i] = T("log.cleanup.reason.max_size", "size_mb", cfg.MaxTotalMB)
+			total -= reports[i].Size
+		}
+	}
+
+	var actions, protected []cleanupAction
+	compressCutoff := now.AddDate(0, 0, -cfg.CompressAfterDays)
+	for i, rep := range reports {
+		action := cleanupAction{report: rep, path: filepath.Join(reportDirs[rep.Kind], rep.Name), reason: reasons[i]}
+		switch {
+		case action.reason.Key != "":
+			if _, used := inUse[strings.TrimSuffix(rep.Name, ".gz")]; used {
+				protected = append(protected, action)
+			} else {
+				actions = append(actions, action)
+			}
+		case cfg.CompressAfterDays > 0 && !rep.Compressed && rep.ModifiedAt.Before(compressCutoff):
+			actions = append(actions, action)
+		}
+	}
+	return actions, protected
+}
+
+// reportGroups guarda o grupo de cada relatório ("tipo/nome") junto com a data
+// de modificação em que foi lido, para que as verificações periódicas não
+// reabram relatórios que não mudaram.
+var (
+	reportGroupsMu sync.Mutex
+	reportGroups   = make(map[string]cachedGroup)
+)
+
+type cachedGroup struct {
+	modTime time.Time
+	group   string
+}
+
+// cachedReportGroup retorna reportGroup(rep), lendo o relatório apenas se ele
+// ainda não estiver no cache ou tiver sido modificado desde a última leitura.
+func cachedReportGroup(rep ReportInfo) string {
+	key := rep.Kind + "/" + rep.Name
+	reportGroupsMu.Lock()
+	cached, ok := reportGroups[key]
+	reportGroupsMu.Unlock()
+	if ok && cached.modTime.Equal(rep.ModifiedAt) {
+		return cached.group
+	}
+	group := reportGroup(rep)
+	reportGroupsMu.Lock()
+	reportGroups[key] = cachedGroup{modTime: rep.ModifiedAt, group: group}
+	reportGroupsMu.Unlock()
+	return group
+}
+
+// pruneReportGroups descarta do cache os relatórios que não estão mais em reports.
+func pruneReportGroups(reports []ReportInfo) {
+	current := make(map[string]bool, len(reports))
+	for _, rep := range reports {
+		current[rep.Kind+"/"+rep.Name] = true
+	}
+	reportGroupsMu.Lock()
+	defer reportGroupsMu.Unlock()
+	for key := range reportGroups {
+		if !current[key] {
+			delete(reportGroups, key)
+		}
+	}
+}
+
+// reportGroup identifica o grupo do relatório para a regra keep_last: a raiz
+// coletada, o par origem/destino comparado ou, para cópias, o diretório de saída.
+func reportGroup(rep ReportInfo) string {
+	path := filepath.Join(reportDirs[rep.Kind], rep.Name)
+	switch rep.Kind {
+	case "collection":
+		if header, err := readCollectionHeader(path); err == nil {
+			return header.RootPath
+		}
+	case "comparison":
+		if source, dest, err := readComparisonRoots(path); err == nil {
+			return source + " → " + dest
+		}
+	}
+	return reportDirs[rep.Kind]
+}
+
+// readComparisonRoots lê apenas os diretórios de origem e destino de um
+// relatório de comparação, que vêm antes das listas de arquivos.
+func readComparisonRoots(path string) (string, string, error) {
+	file, err := openReportFile(path)
+	if err != nil {
+		return "", "", err
+	}
+	defer file.Close()
+	var source, dest string
+	errDone := errors.New("done")
+	dec := json.NewDecoder(file)
+	err = walkJSONObject(dec, func(key string) error {
+		switch key {
+		case "source_root":
+			if err := dec.Decode(&source); err != nil {
+				return err
+			}
+		case "destination_root":
+			if err := dec.Decode(&dest); err != nil {
+				return err
+			}
+		default:
+			var skip json.RawMessage
+			return dec.Decode(&skip)
+		}
+		if source != "" && dest != "" {
+			return errDone
+		}
+		return nil
+	})
+	if err != nil && err != errDone {
+		return "", "", err
+	}
+	return source, dest, nil
+}
+
+// CleanupReports remove os relatórios conforme a política de retenção e
+// compacta os antigos. Relatórios em uso nunca são removidos.
+func CleanupReports(ctx context.Context) error {
+	return cleanupReports(ctx, true)
+}
+
+// cleanupReports executa a limpeza. Sem progress (limpeza entre os ciclos de um
+// monitoramento), o progresso da operação em andamento não é alterado.
+func cleanupReports(ctx context.Context, progress bool) error {
+	actions, protected := planCleanup(time.Now())
+	sendLog("log.cleanup.started", "count", len(actions))
+	if progress {
+		state.ResetProgress(int64(len(actions)), 0)
+		sendProgressUpdate("status.cleanup.starting")
+	}
+	for _, a := range protected {
+		sendLog("log.cleanup.in_use", "path", a.path, "reason", a.reason)
+	}
+
+	var deleted, compressed int
+	var freed int64
+	for _, a := range actions {
+		if err := checkPauseAndCancel(ctx); err != nil {
+			return err
+		}
+		if a.reason.Key != "" {
+			if err := os.Remove(a.path); err != nil {
+				sendFileError(CodeDeleteFailed, a.path, err)
+			} else {
+				deleted++
+				freed += a.report.Size
+				sendLog("log.cleanup.deleted", "path", a.path, "reason", a.reason)
+			}
+		} else if err := compressReport(a.path, a.report.ModifiedAt); err != nil {
+			sendFileError(CodeCompressFailed, a.path, err)
+		} else {
+			compressed++
+			sendLog("log.cleanup.compressed", "path", a.path)
+		}
+		if progress {
+			state.IncrementProcessed()
+			sendProgressUpdate("status.cleanup.file", "path", a.report.Name)
+		}
+	}
+	sendLog("log.cleanup.summary", "deleted", deleted, "size_mb", fmt.Sprintf("%.1f", float64(freed)/(1<<20)),
+		"compressed", compressed, "in_use", len(protected))
+	return nil
+}
+
+// compressReport grava path+".gz" e remove o original. A data de modificação é
+// preservada, para que a idade do relatório continue a mesma. Leitores que já
+// abriram o original continuam lendo-o normalmente.
+func compressReport(path string, modTime time.Time) error {
+	in, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+	if isGzipFile(in) {
+		// Já compactado, apenas sem o sufixo.
+		in.Close()
+		return os.Rename(path, path+".gz")
+	}
+
+	tmp := path + ".gz.tmp"
+	out, err := os.Create(tmp)
+	if err != nil {
+		return err
+	}
+	gz := gzip.NewWriter(out)
+	_, err = io.Copy(gz, in)
+	if err == nil {
+		err = gz.Close()
+	}
+	if err == nil {
+		err = out.Close()
+	} else {
+		out.Close()
+	}
+	if err == nil {
+		err = os.Chtimes(tmp, modTime, modTime)
+	}
+	if err == nil {
+		err = os.Rename(tmp, path+".gz")
+	}
+	if err != nil {
+		os.Remove(tmp)
+		return err
+	}
+	return os.Remove(path)
+}
+
+//================================================================//
+// HTTP
+//================================================================//
+
+// handleCleanup inicia a limpeza dos relatórios (POST /api/v1/jobs com {"kind": "cleanup"}).
+func handleCleanup(w http.ResponseWriter, r *http.Request) {
+	startJob(w, "cleanup", nil, CleanupReports)
+}
+
+// handleDeleteReport remove um relatório do tipo kind. Relatórios usados por
+// uma operação em andamento, ou que possa ser repetida, não são removidos.
+func handleDeleteReport(w http.ResponseWriter, r *http.Request, kind string) {
+	name := r.PathValue("name")
+	path, err := reportPath(reportDirs[kind], name)
+	if err != nil {
+		writeError(w, validationError("name", "%v", err))
+		return
+	}
+	if jobID, ok := jobHistory.ReportsInUse()[strings.TrimSuffix(filepath.Base(path), ".gz")]; ok {
+		writeError(w, apiError(http.StatusConflict, ErrCodeReportInUse,
+			fmt.Sprintf("O relatório %s é usado pela operação %s.", name, jobID)))
+		return
+	}
+	if err := os.Remove(path); err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			err = fmt.Errorf("relatório %s %w", name, errNotFound)
+		}
+		writeError(w, err)
+		return
+	}
+	log.Printf("Relatório removido: %s", path)
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// handleDeleteReportByName atende DELETE /reports/{name}, procurando o
+// relatório nos diretórios de saída na ordem de reportKinds.
+func handleDeleteReportByName(w http.ResponseWriter, r *http.Request) {
+	name := r.PathValue("name")
+	for _, kind := range reportKinds {
+		path, err := reportPath(reportDirs[kind], name)
+		if err != nil {
+			writeError(w, validationError("name", "%v", err))
+			return
+		}
+		if _, err := os.Stat(path); err == nil {
+			handleDeleteReport(w, r, kind)
+			return
+		}
+	}
+	writeError(w, fmt.Errorf("relatório %s %w", name, errNotFound))
+}
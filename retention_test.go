@@ -0,0 +1,149 @@
+package main
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestPlanReports(t *testing.T) {
+	now := time.Date(2026, 6, 1, 12, 0, 0, 0, time.UTC)
+	const mb = 1 << 20
+
+	// report descreve um relatório: tipo, nome, idade em dias, tamanho em MB e grupo.
+	type report struct {
+		kind, name string
+		age        int
+		sizeMB     int64
+		group      string
+	}
+	tests := []struct {
+		name      string
+		cfg       ReportConfig
+		reports   []report // do mais recente para o mais antigo
+		inUse     []string
+		actions   []string // "remove:nome:motivo" ou "compacta:nome"
+		protected []string
+	}{
+		{
+			name:    "nada configurado",
+			reports: []report{{"copy", "copy_1.json", 400, 1, ""}},
+		},
+		{
+			name: "keep_last por grupo",
+			cfg:  ReportConfig{KeepLast: 2},
+			reports: []report{
+				{"collection", "source_3.jsonl", 1, 1, "/a"},
+				{"collection", "source_b.jsonl", 2, 1, "/b"},
+				{"collection", "source_2.jsonl", 3, 1, "/a"},
+				{"collection", "source_1.jsonl", 4, 1, "/a"},
+			},
+			actions: []string{"remove:source_1.jsonl:log.cleanup.reason.keep_last"},
+		},
+		{
+			name: "keep_last separa os tipos",
+			cfg:  ReportConfig{KeepLast: 1},
+			reports: []report{
+				{"collection", "source_2.jsonl", 1, 1, "/a"},
+				{"comparison", "comparison_1.json", 2, 1, "/a"},
+				{"collection", "source_1.jsonl", 3, 1, "/a"},
+			},
+			actions: []string{"remove:source_1.jsonl:log.cleanup.reason.keep_last"},
+		},
+		{
+			name: "max_age",
+			cfg:  ReportConfig{MaxAgeDays: 30},
+			reports: []report{
+				{"copy", "copy_2.json", 10, 1, ""},
+				{"copy", "copy_1.json", 40, 1, ""},
+			},
+			actions: []string{"remove:copy_1.json:log.cleanup.reason.max_age"},
+		},
+		{
+			name: "max_total remove os mais antigos primeiro",
+			cfg:  ReportConfig{MaxTotalMB: 3},
+			reports: []report{
+				{"copy", "copy_3.json", 1, 2, ""},
+				{"copy", "copy_2.json", 2, 2, ""},
+				{"copy", "copy_1.json", 3, 2, ""},
+			},
+			actions: []string{
+				"remove:copy_2.json:log.cleanup.reason.max_size",
+				"remove:copy_1.json:log.cleanup.reason.max_size",
+			},
+		},
+		{
+			name: "relatórios em uso ocupam espaço e não são removidos",
+			cfg:  ReportConfig{MaxTotalMB: 4, MaxAgeDays: 30},
+			reports: []report{
+				{"copy", "copy_3.json", 1, 2, ""},
+				{"copy", "copy_2.json", 40, 2, ""},
+				{"copy", "copy_1.json", 50, 2, ""},
+			},
+			inUse:     []string{"copy_2.json"},
+			actions:   []string{"remove:copy_1.json:log.cleanup.reason.max_age"},
+			protected: []string{"copy_2.json"},
+		},
+		{
+			name:      "em uso pelo nome sem .gz",
+			cfg:       ReportConfig{MaxAgeDays: 30},
+			reports:   []report{{"comparison", "comparison_1.json.gz", 40, 1, ""}},
+			inUse:     []string{"comparison_1.json"},
+			protected: []string{"comparison_1.json.gz"},
+		},
+		{
+			name: "compactação dos antigos",
+			cfg:  ReportConfig{CompressAfterDays: 7},
+			reports: []report{
+				{"copy", "copy_3.json", 3, 1, ""},
+				{"copy", "copy_2.json.gz", 10, 1, ""},
+				{"copy", "copy_1.json", 10, 1, ""},
+			},
+			actions: []string{"compacta:copy_1.json"},
+		},
+		{
+			name: "remoção tem precedência sobre a compactação",
+			cfg:  ReportConfig{CompressAfterDays: 7, MaxAgeDays: 30},
+			reports: []report{
+				{"copy", "copy_2.json", 10, 1, ""},
+				{"copy", "copy_1.json", 40, 1, ""},
+			},
+			actions: []string{"compacta:copy_2.json", "remove:copy_1.json:log.cleanup.reason.max_age"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			groups := make(map[string]string)
+			var reports []ReportInfo
+			for _, r := range tt.reports {
+				reports = append(reports, ReportInfo{Kind: r.kind, Name: r.name, Size: r.sizeMB * mb,
+					ModifiedAt: now.AddDate(0, 0, -r.age), Compressed: strings.HasSuffix(r.name, ".gz")})
+				groups[r.name] = r.group
+			}
+			inUse := make(map[string]string)
+			for _, name := range tt.inUse {
+				inUse[name] = "job"
+			}
+
+			actions, protected := planReports(tt.cfg, reports, inUse, now, func(r ReportInfo) string { return groups[r.Name] })
+			var gotActions, gotProtected []string
+			for _, a := range actions {
+				if a.reason.Key != "" {
+					gotActions = append(gotActions, "remove:"+a.report.Name+":"+a.reason.Key)
+				} else {
+					gotActions = append(gotActions, "compacta:"+a.report.Name)
+				}
+			}
+			for _, a := range protected {
+				gotProtected = append(gotProtected, a.report.Name)
+			}
+			if !reflect.DeepEqual(gotActions, tt.actions) {
+				t.Errorf("ações = %q, esperado %q", gotActions, tt.actions)
+			}
+			if !reflect.DeepEqual(gotProtected, tt.protected) {
+				t.Errorf("protegidos = %q, esperado %q", gotProtected, tt.protected)
+			}
+		})
+	}
+}
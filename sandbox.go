@@ -0,0 +1,186 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"sync"
+)
+
+//================================================================//
+// DIRETÓRIOS PERMITIDOS
+//================================================================//
+
+// errOutsideRoots é retornado para caminhos fora dos diretórios permitidos.
+var errOutsideRoots = errors.New("caminho fora dos diretórios permitidos")
+
+// PathSandbox restringe os caminhos aceitos pela API aos diretórios listados em
+// config/allowed_roots.json. Caminhos e raízes são comparados já canonicalizados
+// (absolutos e com links simbólicos resolvidos).
+type PathSandbox struct {
+	mu       sync.Mutex
+	fileName string
+	roots    []string
+}
+
+var sandbox *PathSandbox
+
+func newPathSandbox(fileName string) *PathSandbox {
+	return &PathSandbox{fileName: fileName}
+}
+
+// Load lê a lista de diretórios permitidos. Se o arquivo não existir, ele é
+// criado permitindo apenas o diretório pessoal do usuário.
+func (s *PathSandbox) Load() error {
+	var configured []string
+	err := readJSONFile(s.fileName, &configured)
+	if errors.Is(err, os.ErrNotExist) {
+		home, herr := os.UserHomeDir()
+		if herr != nil {
+			return herr
+		}
+		configured = []string{home}
+		if err := writeJSONFile(s.fileName, configured); err != nil {
+			return err
+		}
+		log.Printf("%s criado permitindo apenas %s; edite-o para liberar outros diretórios.", s.fileName, home)
+	} else if err != nil {
+		return err
+	}
+
+	roots := make([]string, 0, len(configured))
+	for _, root := range configured {
+		canonical, err := canonicalPath(root)
+		if err != nil {
+			log.Printf("Diretório permitido ignorado (%s): %v", root, err)
+			continue
+		}
+		roots = append(roots, canonical)
+	}
+	s.mu.Lock()
+	s.roots = roots
+	s.mu.Unlock()
+	log.Printf("Diretórios permitidos: %s", strings.Join(roots, ", "))
+	return nil
+}
+
+func (s *PathSandbox) Roots() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]string(nil), s.roots...)
+}
+
+// Resolve canonicaliza path e verifica se ele está dentro de um diretório
+// permitido. O caminho não precisa existir (ex.: destino ainda não criado).
+func (s *PathSandbox) Resolve(path string) (string, error) {
+	if strings.TrimSpace(path) == "" {
+		return "", errors.New("caminho não informado")
+	}
+	canonical, err := canonicalPath(path)
+	if err != nil {
+		return "", err
+	}
+	for _, root := range s.Roots() {
+		if within(root, canonical) {
+			return canonical, nil
+		}
+	}
+	return "", fmt.Errorf("%w: %s", errOutsideRoots, path)
+}
+
+// canonicalPath torna path absoluto e resolve os links simbólicos do maior
+// prefixo existente, preservando o restante do caminho.
+func canonicalPath(path string) (string, error) {
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		return "", err
+	}
+	existing, rest := abs, ""
+	for {
+		resolved, err := filepath.EvalSymlinks(existing)
+		if err == nil {
+			return filepath.Join(resolved, rest), nil
+		}
+		if !errors.Is(err, os.ErrNotExist) {
+			return "", err
+		}
+		parent := filepath.Dir(existing)
+		if parent == existing {
+			return abs, nil
+		}
+		rest = filepath.Join(filepath.Base(existing), rest)
+		existing = parent
+	}
+}
+
+// within indica se path é root ou está abaixo dele. Ambos devem estar canonicalizados.
+func within(root, path string) bool {
+	rel, err := filepath.Rel(root, path)
+	if err != nil {
+		return false
+	}
+	return rel == "." || (rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator)) && !filepath.IsAbs(rel))
+}
+
+// destinationPath confere que o diretório de dst, com os links simbólicos
+// resolvidos, continua dentro de root (já canonicalizado) e retorna dst
+// reescrito sobre esse diretório. Assim um link em um componente intermediário
+// do destino não desvia a gravação para fora da raiz da operação.
+func destinationPath(root, dst string) (string, error) {
+	dir, err := canonicalPath(filepath.Dir(dst))
+	if err != nil {
+		return "", err
+	}
+	if !within(root, dir) {
+		return "", fmt.Errorf("%w: %s leva a %s, fora de %s", errOutsideRoots, filepath.Dir(dst), dir, root)
+	}
+	return filepath.Join(dir, filepath.Base(dst)), nil
+}
+
+// makeDestinationDirs cria os diretórios que faltam para dst dentro de root e
+// retorna o caminho verificado por destinationPath. A verificação é repetida
+// após a criação, caso algum componente tenha sido trocado por um link.
+func makeDestinationDirs(root, dst string) (string, error) {
+	path, err := destinationPath(root, dst)
+	if err != nil {
+		return "", err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), os.ModePerm); err != nil {
+		return "", err
+	}
+	again, err := destinationPath(root, path)
+	if err != nil {
+		return "", err
+	}
+	if again != path {
+		return "", fmt.Errorf("%w: %s mudou durante a cópia", errOutsideRoots, filepath.Dir(dst))
+	}
+	return path, nil
+}
+
+//================================================================//
+// NOMES DE RELATÓRIOS
+//================================================================//
+
+// reportNamePattern aceita apenas nomes simples de arquivo, sem diretórios.
+var reportNamePattern = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9._-]*$`)
+
+// reportPath valida o nome de um relatório recebido pela API e retorna seu
+// caminho dentro do diretório de saída dir. Se o relatório tiver sido
+// compactado depois de referenciado, retorna o caminho da versão .gz.
+func reportPath(dir, name string) (string, error) {
+	if !reportNamePattern.MatchString(name) || strings.Contains(name, "..") {
+		return "", fmt.Errorf("nome de relatório inválido: %q", name)
+	}
+	path := filepath.Join(dir, name)
+	if _, err := os.Stat(path); errors.Is(err, os.ErrNotExist) {
+		if _, err := os.Stat(path + ".gz"); err == nil {
+			return path + ".gz", nil
+		}
+	}
+	return path, nil
+}
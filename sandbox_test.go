@@ -0,0 +1,146 @@
+package main
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestWithin(t *testing.T) {
+	root := filepath.FromSlash("/srv/dados")
+	tests := []struct {
+		path string
+		want bool
+	}{
+		{"/srv/dados", true},
+		{"/srv/dados/a/b", true},
+		{"/srv/dados/..a", true},
+		{"/srv/dadosx", false},
+		{"/srv", false},
+		{"/srv/outros/a", false},
+		{"/", false},
+	}
+	for _, tt := range tests {
+		if got := within(root, filepath.FromSlash(tt.path)); got != tt.want {
+			t.Errorf("within(%q, %q) = %v, esperado %v", root, tt.path, got, tt.want)
+		}
+	}
+}
+
+// sandboxTree cria raiz/{dados,fora} e os links dados/atalho -> fora e
+// dados/interno -> dados/sub, retornando a raiz canonicalizada.
+func sandboxTree(t *testing.T) string {
+	t.Helper()
+	base, err := filepath.EvalSymlinks(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, dir := range []string{"dados/sub", "fora"} {
+		if err := os.MkdirAll(filepath.Join(base, dir), 0o755); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := os.Symlink(filepath.Join(base, "fora"), filepath.Join(base, "dados", "atalho")); err != nil {
+		t.Skipf("links simbólicos indisponíveis: %v", err)
+	}
+	if err := os.Symlink("sub", filepath.Join(base, "dados", "interno")); err != nil {
+		t.Fatal(err)
+	}
+	return base
+}
+
+func TestCanonicalPath(t *testing.T) {
+	base := sandboxTree(t)
+	tests := []struct {
+		name string
+		path string
+		want string
+	}{
+		{"existente", "dados/sub", "dados/sub"},
+		{"inexistente", "dados/sub/novo/arquivo", "dados/sub/novo/arquivo"},
+		{"link no meio", "dados/atalho/novo", "fora/novo"},
+		{"link relativo", "dados/interno/x", "dados/sub/x"},
+		{"ponto-ponto", "dados/sub/../../fora", "fora"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := canonicalPath(filepath.Join(base, tt.path))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if want := filepath.Join(base, tt.want); got != want {
+				t.Errorf("canonicalPath(%q) = %q, esperado %q", tt.path, got, want)
+			}
+		})
+	}
+}
+
+func TestSandboxResolve(t *testing.T) {
+	base := sandboxTree(t)
+	s := &PathSandbox{roots: []string{filepath.Join(base, "dados")}}
+	tests := []struct {
+		name string
+		path string
+		ok   bool
+	}{
+		{"raiz", "dados", true},
+		{"abaixo da raiz", "dados/sub/novo", true},
+		{"link interno", "dados/interno/a", true},
+		{"link para fora", "dados/atalho/a", false},
+		{"fora", "fora", false},
+		{"ponto-ponto", "dados/../fora", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := s.Resolve(filepath.Join(base, tt.path))
+			if tt.ok && err != nil {
+				t.Errorf("Resolve(%q): %v", tt.path, err)
+			}
+			if !tt.ok && !errors.Is(err, errOutsideRoots) {
+				t.Errorf("Resolve(%q) = %v, esperado errOutsideRoots", tt.path, err)
+			}
+		})
+	}
+	if _, err := s.Resolve(" "); err == nil {
+		t.Error("Resolve de caminho vazio não retornou erro")
+	}
+}
+
+func TestMakeDestinationDirs(t *testing.T) {
+	base := sandboxTree(t)
+	root := filepath.Join(base, "dados")
+	tests := []struct {
+		name string
+		dst  string
+		want string // vazio quando deve ser recusado
+	}{
+		{"diretórios novos", "dados/a/b/arquivo", "dados/a/b/arquivo"},
+		{"link interno", "dados/interno/c/arquivo", "dados/sub/c/arquivo"},
+		{"link intermediário para fora", "dados/atalho/d/arquivo", ""},
+		{"link final não é seguido", "dados/atalho", "dados/atalho"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := makeDestinationDirs(root, filepath.Join(base, tt.dst))
+			if tt.want == "" {
+				if !errors.Is(err, errOutsideRoots) {
+					t.Errorf("makeDestinationDirs(%q) = %q, %v; esperado errOutsideRoots", tt.dst, got, err)
+				}
+				if _, err := os.Stat(filepath.Join(base, "fora", "d")); err == nil {
+					t.Error("diretório criado fora da raiz")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if want := filepath.Join(base, tt.want); got != want {
+				t.Errorf("makeDestinationDirs(%q) = %q, esperado %q", tt.dst, got, want)
+			}
+			if _, err := os.Stat(filepath.Dir(got)); err != nil {
+				t.Errorf("diretório não criado: %v", err)
+			}
+		})
+	}
+}
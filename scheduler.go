@@ -0,0 +1,464 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+//================================================================//
+// AGENDAMENTO (CRON)
+//================================================================//
+
+// CronSchedule representa uma expressão cron de 5 campos
+// (minuto, hora, dia do mês, mês, dia da semana) já interpretada.
+type CronSchedule struct {
+	minute, hour, dom, month, dow uint64
+	domAny, dowAny                bool
+}
+
+var cronMacros = map[string]string{
+	"@yearly":   "0 0 1 1 *",
+	"@annually": "0 0 1 1 *",
+	"@monthly":  "0 0 1 * *",
+	"@weekly":   "0 0 * * 0",
+	"@daily":    "0 0 * * *",
+	"@midnight": "0 0 * * *",
+	"@hourly":   "0 * * * *",
+}
+
+// ParseCron interpreta expressões como "30 22 * * 1-5" ou "*/15 * * * *".
+// Aceita listas, intervalos, passos e as macros @daily, @hourly etc.
+func ParseCron(expr string) (*CronSchedule, error) {
+	expr = strings.TrimSpace(expr)
+	if macro, ok := cronMacros[strings.ToLower(expr)]; ok {
+		expr = macro
+	}
+	fields := strings.Fields(expr)
+	if len(fields) != 5 {
+		return nil, fmt.Errorf("expressão cron %q deve ter 5 campos", expr)
+	}
+
+	var c CronSchedule
+	var err error
+	if c.minute, err = parseCronField(fields[0], 0, 59); err != nil {
+		return nil, fmt.Errorf("minuto: %w", err)
+	}
+	if c.hour, err = parseCronField(fields[1], 0, 23); err != nil {
+		return nil, fmt.Errorf("hora: %w", err)
+	}
+	if c.dom, err = parseCronField(fields[2], 1, 31); err != nil {
+		return nil, fmt.Errorf("dia do mês: %w", err)
+	}
+	if c.month, err = parseCronField(fields[3], 1, 12); err != nil {
+		return nil, fmt.Errorf("mês: %w", err)
+	}
+	if c.dow, err = parseCronField(fields[4], 0, 7); err != nil {
+		return nil, fmt.Errorf("dia da semana: %w", err)
+	}
+	// Domingo pode ser escrito como 0 ou 7.
+	if c.dow&(1<<7) != 0 {
+		c.dow |= 1
+	}
+	// Como no Vixie cron, um campo que começa com "*" (ex.: "*/2") não restringe o dia.
+	c.domAny = strings.HasPrefix(fields[2], "*")
+	c.dowAny = strings.HasPrefix(fields[4], "*")
+	return &c, nil
+}
+
+func parseCronField(field string, min, max int) (uint64, error) {
+	var bits uint64
+	for _, part := range strings.Split(field, ",") {
+		rangePart, step := part, 1
+		if i := strings.Index(part, "/"); i >= 0 {
+			n, err := strconv.Atoi(part[i+1:])
+			if err != nil || n <= 0 {
+				return 0, fmt.Errorf("passo inválido em %q", part)
+			}
+			rangePart, step = part[:i], n
+		}
+
+		lo, hi := min, max
+		switch {
+		case rangePart == "*":
+		case strings.Contains(rangePart, "-"):
+			bounds := strings.SplitN(rangePart, "-", 2)
+			var err1, err2 error
+			lo, err1 = strconv.Atoi(bounds[0])
+			hi, err2 = strconv.Atoi(bounds[1])
+			if err1 != nil || err2 != nil {
+				return 0, fmt.Errorf("intervalo inválido %q", part)
+			}
+		default:
+			n, err := strconv.Atoi(rangePart)
+			if err != nil {
+				return 0, fmt.Errorf("valor inválido %q", part)
+			}
+			lo = n
+			if step == 1 {
+				hi = n
+			}
+		}
+		if lo < min || hi > max || lo > hi {
+			return 0, fmt.Errorf("valor fora do intervalo %d-%d em %q", min, max, part)
+		}
+		for v := lo; v <= hi; v += step {
+			bits |= 1 << uint(v)
+		}
+	}
+	return bits, nil
+}
+
+func (c *CronSchedule) matchesDay(t time.Time) bool {
+	domMatch := c.dom&(1<<uint(t.Day())) != 0
+	dowMatch := c.dow&(1<<uint(t.Weekday())) != 0
+	// Como no cron tradicional: se os dois campos forem restritos, basta um coincidir.
+	switch {
+	case c.domAny && c.dowAny:
+		return true
+	case c.domAny:
+		return dowMatch
+	case c.dowAny:
+		return domMatch
+	default:
+		return domMatch || dowMatch
+	}
+}
+
+// Next retorna o primeiro instante, estritamente após t, em que a expressão é satisfeita.
+// Retorna o tempo zero se nenhum horário for encontrado nos próximos 5 anos.
+func (c *CronSchedule) Next(t time.Time) time.Time {
+	// Truncate opera no tempo absoluto e desalinharia as horas em fusos com
+	// deslocamento fracionário (ex.: +05:30); por isso as horas e os dias são
+	// montados com time.Date no fuso de t. O primeiro passo descarta os segundos
+	// locais sem time.Date, que na hora repetida do fim do horário de verão
+	// poderia voltar para a primeira ocorrência, anterior a t.
+	t = t.Add(time.Minute - time.Duration(t.Second())*time.Second - time.Duration(t.Nanosecond()))
+	limit := t.AddDate(5, 0, 0)
+	for t.Before(limit) {
+		if c.month&(1<<uint(t.Month())) == 0 {
+			t = advance(t, time.Date(t.Year(), t.Month()+1, 1, 0, 0, 0, 0, t.Location()))
+			continue
+		}
+		if !c.matchesDay(t) {
+			t = advance(t, time.Date(t.Year(), t.Month(), t.Day()+1, 0, 0, 0, 0, t.Location()))
+			continue
+		}
+		if c.hour&(1<<uint(t.Hour())) == 0 {
+			t = advance(t, time.Date(t.Year(), t.Month(), t.Day(), t.Hour()+1, 0, 0, 0, t.Location()))
+			continue
+		}
+		if c.minute&(1<<uint(t.Minute())) == 0 {
+			t = t.Add(time.Minute)
+			continue
+		}
+		return t
+	}
+	return time.Time{}
+}
+
+// advance retorna next, o início do próximo mês, dia ou hora de t. Se esse
+// horário foi pulado pelo início do horário de verão, time.Date pode devolver
+// um instante que não é posterior a t; nesse caso o avanço é até o início da
+// próxima hora local, contado no tempo absoluto.
+func advance(t, next time.Time) time.Time {
+	if next.After(t) {
+		return next
+	}
+	return t.Add(time.Duration(60-t.Minute()) * time.Minute)
+}
+
+//================================================================//
+// SCHEDULER
+//================================================================//
+
+// ScheduledSync executa periodicamente a sincronização de um perfil.
+type ScheduledSync struct {
+	Name       string    `json:"name"`
+	Profile    string    `json:"profile"`
+	Cron       string    `json:"cron"`
+	Overlap    string    `json:"overlap"` // "skip" ou "queue"
+	Enabled    bool      `json:"enabled"`
+	LastRun    time.Time `json:"last_run"`
+	LastResult string    `json:"last_result"` // "ok", "failed", "canceled", "skipped"
+	NextRun    time.Time `json:"next_run"`
+}
+
+type scheduleEntry struct {
+	ScheduledSync
+	cron *CronSchedule
+}
+
+// Scheduler dispara as sincronizações agendadas e persiste os agendamentos em disco.
+type Scheduler struct {
+	mu       sync.Mutex
+	fileName string
+	entries  map[string]*scheduleEntry
+	queue    []string
+}
+
+var scheduler *Scheduler
+
+func newScheduler(fileName string) *Scheduler {
+	return &Scheduler{fileName: fileName, entries: make(map[string]*scheduleEntry)}
+}
+
+// Load lê os agendamentos salvos. Um arquivo inexistente não é considerado erro.
+func (s *Scheduler) Load() error {
+	var saved []ScheduledSync
+	if err := readJSONFile(s.fileName, &saved); err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return nil
+		}
+		return err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	now := time.Now()
+	for _, sc := range saved {
+		cron, err := ParseCron(sc.Cron)
+		if err != nil {
+			log.Printf("Agendamento %s ignorado: %v", sc.Name, err)
+			continue
+		}
+		sc.NextRun = cron.Next(now)
+		s.entries[sc.Name] = &scheduleEntry{ScheduledSync: sc, cron: cron}
+	}
+	return nil
+}
+
+// saveLocked grava os agendamentos em disco. Deve ser chamado com s.mu travado.
+func (s *Scheduler) saveLocked() error {
+	return writeJSONFile(s.fileName, s.listLocked())
+}
+
+func (s *Scheduler) listLocked() []ScheduledSync {
+	list := make([]ScheduledSync, 0, len(s.entries))
+	for _, e := range s.entries {
+		list = append(list, e.ScheduledSync)
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].Name < list[j].Name })
+	return list
+}
+
+func (s *Scheduler) List() []ScheduledSync {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.listLocked()
+}
+
+// UsingProfile retorna, em ordem, os nomes dos agendamentos que executam o perfil.
+func (s *Scheduler) UsingProfile(profile string) []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var names []string
+	for _, sc := range s.listLocked() {
+		if sc.Profile == profile {
+			names = append(names, sc.Name)
+		}
+	}
+	return names
+}
+
+// Put cria ou substitui um agendamento, preservando o histórico da última execução.
+func (s *Scheduler) Put(sc ScheduledSync) (ScheduledSync, error) {
+	if sc.Name == "" || sc.Profile == "" {
+		return sc, validationError("name", "nome e perfil são obrigatórios")
+	}
+	if _, ok := profiles.Get(sc.Profile); !ok {
+		return sc, validationError("profile", "perfil %q não encontrado", sc.Profile)
+	}
+	if sc.Overlap == "" {
+		sc.Overlap = "skip"
+	}
+	if sc.Overlap != "skip" && sc.Overlap != "queue" {
+		return sc, validationError("overlap", "política de sobreposição inválida: %q", sc.Overlap)
+	}
+	cron, err := ParseCron(sc.Cron)
+	if err != nil {
+		return sc, validationError("cron", "%v", err)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if old, ok := s.entries[sc.Name]; ok {
+		sc.LastRun, sc.LastResult = old.LastRun, old.LastResult
+	}
+	sc.NextRun = cron.Next(time.Now())
+	s.entries[sc.Name] = &scheduleEntry{ScheduledSync: sc, cron: cron}
+	return sc, s.saveLocked()
+}
+
+func (s *Scheduler) Delete(name string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.entries[name]; !ok {
+		return fmt.Errorf("agendamento %q %w", name, errNotFound)
+	}
+	delete(s.entries, name)
+	return s.saveLocked()
+}
+
+func (s *Scheduler) run() {
+	ticker := time.NewTicker(5 * time.Second)
+	defer ticker.Stop()
+	for now := range ticker.C {
+		s.tick(now)
+	}
+}
+
+// tick dispara os agendamentos vencidos e, se o servidor estiver livre,
+// executa o próximo item da fila.
+func (s *Scheduler) tick(now time.Time) {
+	s.mu.Lock()
+	var due []*scheduleEntry
+	for _, e := range s.entries {
+		if e.Enabled && !e.NextRun.IsZero() && !now.Before(e.NextRun) {
+			e.NextRun = e.cron.Next(now)
+			due = append(due, e)
+		}
+	}
+	s.mu.Unlock()
+
+	for _, e := range due {
+		s.trigger(e.Name)
+	}
+	s.drainQueue()
+}
+
+// trigger tenta iniciar a sincronização. Se outra operação estiver em andamento,
+// a execução é ignorada ou enfileirada conforme a política do agendamento.
+func (s *Scheduler) trigger(name string) {
+	if s.start(name) {
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	e, ok := s.entries[name]
+	if !ok {
+		return
+	}
+	if e.Overlap == "queue" {
+		for _, queued := range s.queue {
+			if queued == name {
+				return
+			}
+		}
+		s.queue = append(s.queue, name)
+		sendLog("log.schedule.queued", "schedule", name)
+		return
+	}
+	e.LastRun, e.LastResult = time.Now(), "skipped"
+	if err := s.saveLocked(); err != nil {
+		log.Printf("Falha ao salvar agendamentos: %v", err)
+	}
+	sendWarn("log.schedule.skipped", "schedule", name)
+}
+
+func (s *Scheduler) drainQueue() {
+	s.mu.Lock()
+	if len(s.queue) == 0 || state.IsRunning() {
+		s.mu.Unlock()
+		return
+	}
+	name := s.queue[0]
+	s.mu.Unlock()
+
+	if s.start(name) {
+		s.mu.Lock()
+		if len(s.queue) > 0 && s.queue[0] == name {
+			s.queue = s.queue[1:]
+		}
+		s.mu.Unlock()
+	}
+}
+
+// start inicia a sincronização encadeada (coleta, comparação e cópia) do
+// perfil do agendamento. Retorna false se outra operação estiver em andamento.
+func (s *Scheduler) start(name string) bool {
+	s.mu.Lock()
+	e, ok := s.entries[name]
+	if !ok {
+		s.mu.Unlock()
+		return true // removido enquanto aguardava; nada a fazer
+	}
+	sc := e.ScheduledSync
+	s.mu.Unlock()
+
+	startedAt := time.Now()
+	_, done, err := startProfileSync(sc.Profile)
+	if errors.Is(err, errOperationRunning) {
+		return false
+	}
+	if err != nil {
+		logEvent(LevelError, CodeJobFailed, "", T("log.schedule.failed", "schedule", sc.Name, "error", err))
+		s.recordResult(sc.Name, startedAt, err)
+		return true
+	}
+	sendLog("log.schedule.started", "schedule", sc.Name)
+	go func() {
+		s.recordResult(sc.Name, startedAt, <-done)
+	}()
+	return true
+}
+
+func (s *Scheduler) recordResult(name string, startedAt time.Time, err error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	e, ok := s.entries[name]
+	if !ok {
+		return
+	}
+	e.LastRun = startedAt
+	switch {
+	case errors.Is(err, context.Canceled):
+		e.LastResult = "canceled"
+	case err != nil:
+		e.LastResult = "failed"
+	default:
+		e.LastResult = "ok"
+	}
+	if err := s.saveLocked(); err != nil {
+		log.Printf("Falha ao salvar agendamentos: %v", err)
+	}
+}
+
+//================================================================//
+// HTTP
+//================================================================//
+
+func handleSchedules(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(scheduler.List())
+	case http.MethodPost:
+		var req ScheduledSync
+		if err := decodeJSON(w, r, &req); err != nil {
+			writeError(w, err)
+			return
+		}
+		saved, err := scheduler.Put(req)
+		if err != nil {
+			writeError(w, err)
+			return
+		}
+		writeJSON(w, http.StatusOK, saved)
+	case http.MethodDelete:
+		if err := scheduler.Delete(r.URL.Query().Get("name")); err != nil {
+			writeError(w, err)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
@@ -0,0 +1,116 @@
+package main
+
+import (
+	"testing"
+	"time"
+	_ "time/tzdata"
+)
+
+func TestParseCronErrors(t *testing.T) {
+	tests := []struct {
+		name string
+		expr string
+	}{
+		{"poucos campos", "* * * *"},
+		{"muitos campos", "* * * * * *"},
+		{"minuto fora do intervalo", "60 * * * *"},
+		{"hora fora do intervalo", "0 24 * * *"},
+		{"dia do mês zero", "0 0 0 * *"},
+		{"intervalo invertido", "0 10-5 * * *"},
+		{"passo zero", "*/0 * * * *"},
+		{"valor inválido", "a * * * *"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := ParseCron(tt.expr); err == nil {
+				t.Errorf("ParseCron(%q) não retornou erro", tt.expr)
+			}
+		})
+	}
+}
+
+func TestCronNext(t *testing.T) {
+	utc := time.UTC
+	kolkata := mustLoadLocation(t, "Asia/Kolkata")  // +05:30
+	eucla := mustLoadLocation(t, "Australia/Eucla") // +08:45
+	newYork := mustLoadLocation(t, "America/New_York")
+
+	tests := []struct {
+		name string
+		expr string
+		from time.Time
+		want time.Time
+	}{
+		{"próximo minuto", "* * * * *",
+			time.Date(2026, 1, 1, 10, 0, 30, 0, utc), time.Date(2026, 1, 1, 10, 1, 0, 0, utc)},
+		{"estritamente depois", "0 10 * * *",
+			time.Date(2026, 1, 1, 10, 0, 0, 0, utc), time.Date(2026, 1, 2, 10, 0, 0, 0, utc)},
+		{"passo de minutos", "*/15 * * * *",
+			time.Date(2026, 1, 1, 10, 16, 0, 0, utc), time.Date(2026, 1, 1, 10, 30, 0, 0, utc)},
+		{"dias úteis", "30 22 * * 1-5",
+			time.Date(2026, 10, 16, 23, 0, 0, 0, utc), time.Date(2026, 10, 19, 22, 30, 0, 0, utc)}, // sexta -> segunda
+		{"domingo como 7", "0 0 * * 7",
+			time.Date(2026, 10, 16, 0, 0, 0, 0, utc), time.Date(2026, 10, 18, 0, 0, 0, 0, utc)},
+		{"macro", "@monthly",
+			time.Date(2026, 10, 16, 0, 0, 0, 0, utc), time.Date(2026, 11, 1, 0, 0, 0, 0, utc)},
+		{"dia do mês ou da semana", "0 0 13 * 5",
+			time.Date(2026, 10, 10, 0, 0, 0, 0, utc), time.Date(2026, 10, 13, 0, 0, 0, 0, utc)}, // dia 13 antes da sexta
+		{"dia da semana ou do mês", "0 0 13 * 5",
+			time.Date(2026, 10, 14, 0, 0, 0, 0, utc), time.Date(2026, 10, 16, 0, 0, 0, 0, utc)}, // sexta antes do dia 13
+		{"dia do mês com passo não restringe", "0 0 */2 * 1",
+			time.Date(2026, 10, 14, 0, 0, 0, 0, utc), time.Date(2026, 10, 19, 0, 0, 0, 0, utc)}, // só segundas
+		{"dia da semana com passo não restringe", "0 0 15 * */2",
+			time.Date(2026, 10, 14, 0, 0, 0, 0, utc), time.Date(2026, 10, 15, 0, 0, 0, 0, utc)}, // só o dia 15
+		{"fevereiro bissexto", "0 0 29 2 *",
+			time.Date(2026, 3, 1, 0, 0, 0, 0, utc), time.Date(2028, 2, 29, 0, 0, 0, 0, utc)},
+		{"hora cheia em +05:30", "0 * * * *",
+			time.Date(2026, 1, 1, 10, 15, 0, 0, kolkata), time.Date(2026, 1, 1, 11, 0, 0, 0, kolkata)},
+		{"hora restrita em +05:30", "0 9 * * *",
+			time.Date(2026, 1, 1, 7, 45, 0, 0, kolkata), time.Date(2026, 1, 1, 9, 0, 0, 0, kolkata)},
+		{"hora restrita em +08:45", "30 6 * * *",
+			time.Date(2026, 1, 1, 4, 50, 0, 0, eucla), time.Date(2026, 1, 1, 6, 30, 0, 0, eucla)},
+		{"início do horário de verão", "30 2 * * *", // 02:30 não existe em 8/3/2026
+			time.Date(2026, 3, 8, 0, 0, 0, 0, newYork), time.Date(2026, 3, 9, 2, 30, 0, 0, newYork)},
+		{"hora seguinte ao início do horário de verão", "0 3 * * *",
+			time.Date(2026, 3, 8, 0, 0, 0, 0, newYork), time.Date(2026, 3, 8, 3, 0, 0, 0, newYork)},
+		{"fim do horário de verão", "0 2 * * *",
+			time.Date(2026, 11, 1, 0, 30, 0, 0, newYork), time.Date(2026, 11, 1, 2, 0, 0, 0, newYork)},
+		{"nunca satisfeita", "0 0 31 2 *",
+			time.Date(2026, 1, 1, 0, 0, 0, 0, utc), time.Time{}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c, err := ParseCron(tt.expr)
+			if err != nil {
+				t.Fatalf("ParseCron(%q): %v", tt.expr, err)
+			}
+			if got := c.Next(tt.from); !got.Equal(tt.want) {
+				t.Errorf("Next(%v) = %v, esperado %v", tt.from, got, tt.want)
+			}
+		})
+	}
+}
+
+// TestCronNextAfterRepeatedHour verifica que, na hora repetida do fim do
+// horário de verão, Next nunca retorna um instante anterior ao informado.
+func TestCronNextAfterRepeatedHour(t *testing.T) {
+	newYork := mustLoadLocation(t, "America/New_York")
+	c, err := ParseCron("* * * * *")
+	if err != nil {
+		t.Fatal(err)
+	}
+	// 01:30 EST, a segunda ocorrência de 01:30 em 1/11/2026.
+	from := time.Date(2026, 11, 1, 5, 30, 0, 0, time.UTC).Add(time.Hour).In(newYork)
+	if got := c.Next(from); !got.Equal(from.Add(time.Minute)) {
+		t.Errorf("Next(%v) = %v, esperado %v", from, got, from.Add(time.Minute))
+	}
+}
+
+func mustLoadLocation(t *testing.T, name string) *time.Location {
+	t.Helper()
+	loc, err := time.LoadLocation(name)
+	if err != nil {
+		t.Fatalf("fuso %s: %v", name, err)
+	}
+	return loc
+}
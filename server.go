@@ -0,0 +1,198 @@
+package main
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"log"
+	"math/big"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+//================================================================//
+// CONFIGURAÇÃO DO SERVIDOR E HTTPS
+//================================================================//
+
+// ServerConfig é lida de config/server.json.
+type ServerConfig struct {
+	Address string    `json:"address"`
+	TLS     TLSConfig `json:"tls"`
+	// Language é o idioma do texto gravado nos logs das operações ("pt-BR" ou "en").
+	Language string       `json:"language,omitempty"`
+	Reports  ReportConfig `json:"reports"`
+}
+
+// ReportConfig controla o armazenamento dos relatórios.
+type ReportConfig struct {
+	// Compress grava os relatórios novos compactados com gzip (.gz).
+	Compress bool `json:"compress"`
+	// CompressAfterDays compacta os relatórios não compactados mais antigos que
+	// esse número de dias. Zero desativa.
+	CompressAfterDays int `json:"compress_after_days"`
+	// KeepLast mantém apenas os N relatórios mais recentes de cada grupo: a raiz
+	// coletada, o par origem/destino comparado ou, na cópia, todos. Zero desativa.
+	KeepLast int `json:"keep_last"`
+	// MaxAgeDays remove os relatórios mais antigos que esse número de dias. Zero desativa.
+	MaxAgeDays int `json:"max_age_days"`
+	// MaxTotalMB remove os relatórios mais antigos enquanto o total dos três
+	// diretórios de saída passar desse tamanho. Zero desativa.
+	MaxTotalMB int64 `json:"max_total_mb"`
+}
+
+// retains informa se alguma regra de remoção está ativa.
+func (c ReportConfig) retains() bool {
+	return c.KeepLast > 0 || c.MaxAgeDays > 0 || c.MaxTotalMB > 0
+}
+
+// TLSConfig habilita HTTPS. Sem cert_file e key_file, um certificado
+// autoassinado é gerado em config/tls na primeira execução.
+type TLSConfig struct {
+	Enabled  bool   `json:"enabled"`
+	CertFile string `json:"cert_file,omitempty"`
+	KeyFile  string `json:"key_file,omitempty"`
+}
+
+const selfSignedValidity = 365 * 24 * time.Hour
+
+func defaultServerConfig() ServerConfig {
+	return ServerConfig{Address: ":8080", Language: defaultLanguage, Reports: defaultReportConfig()}
+}
+
+func defaultReportConfig() ReportConfig {
+	return ReportConfig{Compress: true, CompressAfterDays: 7}
+}
+
+// loadServerConfig lê a configuração do servidor, criando o arquivo com os
+// valores padrão se ele não existir.
+func loadServerConfig(fileName string) (ServerConfig, error) {
+	cfg := defaultServerConfig()
+	err := readJSONFile(fileName, &cfg)
+	if errors.Is(err, os.ErrNotExist) {
+		return cfg, writeJSONFile(fileName, cfg)
+	}
+	if err != nil {
+		return cfg, err
+	}
+	if cfg.Address == "" {
+		cfg.Address = defaultServerConfig().Address
+	}
+	if cfg.Language == "" {
+		cfg.Language = defaultLanguage
+	} else if lang := matchLanguage(cfg.Language); lang != "" {
+		cfg.Language = lang
+	} else {
+		return cfg, fmt.Errorf("language: idioma não suportado %q (use %s)", cfg.Language, strings.Join(supportedLanguages, " ou "))
+	}
+	for field, v := range map[string]int64{
+		"compress_after_days": int64(cfg.Reports.CompressAfterDays),
+		"keep_last":           int64(cfg.Reports.KeepLast),
+		"max_age_days":        int64(cfg.Reports.MaxAgeDays),
+		"max_total_mb":        cfg.Reports.MaxTotalMB,
+	} {
+		if v < 0 {
+			return cfg, fmt.Errorf("reports.%s: deve ser zero (desativado) ou positivo", field)
+		}
+	}
+	if (cfg.TLS.CertFile == "") != (cfg.TLS.KeyFile == "") {
+		return cfg, errors.New("tls: informe cert_file e key_file juntos")
+	}
+	return cfg, nil
+}
+
+// listenAndServe inicia o servidor em HTTP ou HTTPS, conforme a configuração.
+func listenAndServe(cfg ServerConfig, handler http.Handler) error {
+	server := &http.Server{
+		Addr:              cfg.Address,
+		Handler:           handler,
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+	if !cfg.TLS.Enabled {
+		log.Printf("Servidor iniciado em http://%s", displayAddress(cfg.Address))
+		return server.ListenAndServe()
+	}
+
+	certFile, keyFile := cfg.TLS.CertFile, cfg.TLS.KeyFile
+	if certFile == "" {
+		certFile, keyFile = filepath.Join(configDir, "tls", "cert.pem"), filepath.Join(configDir, "tls", "key.pem")
+		if err := ensureSelfSignedCert(certFile, keyFile); err != nil {
+			return err
+		}
+	}
+	server.TLSConfig = &tls.Config{MinVersion: tls.VersionTLS12}
+	log.Printf("Servidor iniciado em https://%s", displayAddress(cfg.Address))
+	return server.ListenAndServeTLS(certFile, keyFile)
+}
+
+func displayAddress(addr string) string {
+	host, port, err := net.SplitHostPort(addr)
+	if err != nil || host == "" || host == "0.0.0.0" || host == "::" {
+		return "localhost:" + port
+	}
+	return net.JoinHostPort(host, port)
+}
+
+// ensureSelfSignedCert gera um certificado autoassinado para localhost e o nome
+// desta máquina, a menos que já exista um válido por pelo menos mais um dia.
+func ensureSelfSignedCert(certFile, keyFile string) error {
+	if pair, err := tls.LoadX509KeyPair(certFile, keyFile); err == nil {
+		if cert, err := x509.ParseCertificate(pair.Certificate[0]); err == nil && time.Until(cert.NotAfter) > 24*time.Hour {
+			return nil
+		}
+	}
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return err
+	}
+	serial, err := rand.Int(rand.Reader, new(big.Int).Lsh(big.NewInt(1), 128))
+	if err != nil {
+		return err
+	}
+	hosts := []string{"localhost"}
+	if name, err := os.Hostname(); err == nil && name != "localhost" {
+		hosts = append(hosts, name)
+	}
+	now := time.Now()
+	template := &x509.Certificate{
+		SerialNumber:          serial,
+		Subject:               pkix.Name{CommonName: hosts[len(hosts)-1], Organization: []string{"go-sync-tool"}},
+		NotBefore:             now.Add(-time.Hour),
+		NotAfter:              now.Add(selfSignedValidity),
+		KeyUsage:              x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+		BasicConstraintsValid: true,
+		DNSNames:              hosts,
+		IPAddresses:           []net.IP{net.IPv4(127, 0, 0, 1), net.IPv6loopback},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		return err
+	}
+	keyDER, err := x509.MarshalPKCS8PrivateKey(key)
+	if err != nil {
+		return err
+	}
+
+	if err := os.MkdirAll(filepath.Dir(certFile), 0o700); err != nil {
+		return err
+	}
+	if err := os.WriteFile(keyFile, pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: keyDER}), 0o600); err != nil {
+		return err
+	}
+	if err := os.WriteFile(certFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0o644); err != nil {
+		return err
+	}
+	log.Printf("Certificado autoassinado gerado em %s (válido até %s)", certFile, template.NotAfter.Format("02/01/2006"))
+	return nil
+}
@@ -0,0 +1,248 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"sync"
+	"time"
+)
+
+//================================================================//
+// LIMITE DE BANDA E DE ARQUIVOS POR SEGUNDO
+//================================================================//
+
+// ThrottleWindow é um intervalo diário ("22:00" a "06:00") em que os limites
+// não se aplicam. Intervalos que cruzam a meia-noite são permitidos.
+type ThrottleWindow struct {
+	Start string `json:"start"`
+	End   string `json:"end"`
+}
+
+// ThrottleSettings define os limites de uma operação. Zero significa sem limite.
+type ThrottleSettings struct {
+	BytesPerSecond int64            `json:"bytes_per_second"`
+	FilesPerSecond float64          `json:"files_per_second"`
+	FullSpeed      []ThrottleWindow `json:"full_speed_windows"`
+}
+
+// throttleChunk limita quantos bytes são lidos antes de consultar o limitador,
+// para que alterações ao vivo tenham efeito rapidamente.
+const throttleChunk = 64 * 1024
+
+func (s *ThrottleSettings) validate() error {
+	if s.BytesPerSecond < 0 || s.FilesPerSecond < 0 {
+		return fmt.Errorf("limites não podem ser negativos")
+	}
+	for _, w := range s.FullSpeed {
+		if _, err := parseClock(w.Start); err != nil {
+			return err
+		}
+		if _, err := parseClock(w.End); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// parseClock converte "HH:MM" em minutos desde a meia-noite.
+func parseClock(value string) (int, error) {
+	t, err := time.Parse("15:04", value)
+	if err != nil {
+		return 0, fmt.Errorf("horário inválido %q (use HH:MM)", value)
+	}
+	return t.Hour()*60 + t.Minute(), nil
+}
+
+// inFullSpeedWindow indica se t está dentro de alguma janela sem limites.
+func (s *ThrottleSettings) inFullSpeedWindow(t time.Time) bool {
+	now := t.Hour()*60 + t.Minute()
+	for _, w := range s.FullSpeed {
+		start, err1 := parseClock(w.Start)
+		end, err2 := parseClock(w.End)
+		if err1 != nil || err2 != nil {
+			continue
+		}
+		if start <= end && now >= start && now < end {
+			return true
+		}
+		if start > end && (now >= start || now < end) {
+			return true
+		}
+	}
+	return false
+}
+
+// tokenBucket é um balde de fichas que permite "dívida": quem consome além do
+// saldo espera até que a taxa a reponha. Taxa zero desativa o limite.
+type tokenBucket struct {
+	mu     sync.Mutex
+	rate   float64
+	tokens float64
+	last   time.Time
+	now    func() time.Time // relógio usado na reposição; time.Now se nil
+}
+
+func (b *tokenBucket) clock() time.Time {
+	if b.now != nil {
+		return b.now()
+	}
+	return time.Now()
+}
+
+func (b *tokenBucket) setRate(rate float64) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.rate = rate
+	b.tokens = 0
+	b.last = b.clock()
+}
+
+func (b *tokenBucket) take(ctx context.Context, n float64) error {
+	for wait := b.reserve(n); wait > 0; wait = b.reserve(0) {
+		// Espera em pequenos intervalos para perceber mudanças de taxa ao vivo.
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(min(wait, 250*time.Millisecond)):
+		}
+	}
+	return nil
+}
+
+// reserve retira n fichas e retorna quanto tempo falta para quitar a dívida
+// na taxa atual (zero se não houver dívida ou limite).
+func (b *tokenBucket) reserve(n float64) time.Duration {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.refillLocked()
+	b.tokens -= n
+	if b.rate <= 0 || b.tokens >= 0 {
+		return 0
+	}
+	return time.Duration(-b.tokens / b.rate * float64(time.Second))
+}
+
+// refillLocked repõe as fichas acumuladas desde a última chamada, limitando o
+// saldo a um segundo de taxa. Deve ser chamado com b.mu travado.
+func (b *tokenBucket) refillLocked() {
+	now := b.clock()
+	if b.rate <= 0 {
+		b.tokens = 0
+	} else {
+		b.tokens = min(b.rate, b.tokens+now.Sub(b.last).Seconds()*b.rate)
+	}
+	b.last = now
+}
+
+// Throttle aplica os limites da operação em andamento a todas as leituras e
+// escritas de arquivos. Os limites podem ser alterados durante a execução.
+type Throttle struct {
+	mu       sync.Mutex
+	settings ThrottleSettings
+	bytes    tokenBucket
+	files    tokenBucket
+}
+
+var throttle = &Throttle{}
+
+// Configure substitui os limites em vigor.
+func (t *Throttle) Configure(s ThrottleSettings) {
+	t.mu.Lock()
+	t.settings = s
+	t.mu.Unlock()
+	t.bytes.setRate(float64(s.BytesPerSecond))
+	t.files.setRate(s.FilesPerSecond)
+}
+
+func (t *Throttle) Settings() ThrottleSettings {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.settings
+}
+
+func (t *Throttle) limitedNow() bool {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return !t.settings.inFullSpeedWindow(time.Now())
+}
+
+// WaitBytes bloqueia até que n bytes possam ser transferidos.
+func (t *Throttle) WaitBytes(ctx context.Context, n int) error {
+	if !t.limitedNow() {
+		return nil
+	}
+	return t.bytes.take(ctx, float64(n))
+}
+
+// WaitFile bloqueia até que mais um arquivo possa ser processado.
+func (t *Throttle) WaitFile(ctx context.Context) error {
+	if !t.limitedNow() {
+		return nil
+	}
+	return t.files.take(ctx, 1)
+}
+
+// throttledReader aplica o limite de bytes por segundo a um io.Reader e,
+// se progress for verdadeiro, contabiliza os bytes lidos no progresso.
+type throttledReader struct {
+	ctx      context.Context
+	r        io.Reader
+	progress bool
+}
+
+func (tr *throttledReader) Read(p []byte) (int, error) {
+	if len(p) > throttleChunk {
+		p = p[:throttleChunk]
+	}
+	n, err := tr.r.Read(p)
+	if n > 0 {
+		metrics.bytesRead.Add(int64(n))
+		if tr.progress {
+			state.AddProcessedBytes(int64(n))
+		}
+		if werr := throttle.WaitBytes(tr.ctx, n); werr != nil {
+			return n, werr
+		}
+	}
+	return n, err
+}
+
+//================================================================//
+// HTTP
+//================================================================//
+
+// handleThrottle consulta (GET) ou altera ao vivo (POST ou PUT) os limites da operação em andamento.
+func handleThrottle(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+	case http.MethodPost, http.MethodPut:
+		var req ThrottleSettings
+		if err := decodeJSON(w, r, &req); err != nil {
+			writeError(w, err)
+			return
+		}
+		if err := req.validate(); err != nil {
+			writeError(w, validationError("", "%v", err))
+			return
+		}
+		throttle.Configure(req)
+		sendLog("log.throttle.changed", "limits", describeThrottle(req))
+	}
+	writeJSON(w, http.StatusOK, struct {
+		ThrottleSettings
+		LimitedNow bool `json:"limited_now"`
+	}{throttle.Settings(), throttle.limitedNow()})
+}
+
+func describeThrottle(s ThrottleSettings) Text {
+	bytes, files := T("throttle.unlimited"), T("throttle.unlimited")
+	if s.BytesPerSecond > 0 {
+		bytes = T("throttle.bytes", "value", fmt.Sprintf("%.1f", float64(s.BytesPerSecond)/(1024*1024)))
+	}
+	if s.FilesPerSecond > 0 {
+		files = T("throttle.files", "value", fmt.Sprintf("%g", s.FilesPerSecond))
+	}
+	return T("throttle.limits", "bytes", bytes, "files", files)
+}
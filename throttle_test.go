@@ -0,0 +1,88 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestInFullSpeedWindow(t *testing.T) {
+	s := ThrottleSettings{FullSpeed: []ThrottleWindow{
+		{Start: "22:00", End: "06:00"},
+		{Start: "12:00", End: "13:30"},
+		{Start: "xx", End: "10:00"},
+	}}
+	tests := []struct {
+		clock string
+		want  bool
+	}{
+		{"21:59", false},
+		{"22:00", true},
+		{"23:59", true},
+		{"00:00", true},
+		{"05:59", true},
+		{"06:00", false},
+		{"09:00", false}, // janela inválida é ignorada
+		{"12:00", true},
+		{"13:29", true},
+		{"13:30", false},
+	}
+	for _, tt := range tests {
+		now, _ := time.Parse("15:04", tt.clock)
+		if got := s.inFullSpeedWindow(now); got != tt.want {
+			t.Errorf("inFullSpeedWindow(%s) = %v, esperado %v", tt.clock, got, tt.want)
+		}
+	}
+}
+
+func TestTokenBucketReserve(t *testing.T) {
+	now := time.Date(2026, 3, 1, 10, 0, 0, 0, time.UTC)
+	b := tokenBucket{now: func() time.Time { return now }}
+	b.setRate(100)
+	steps := []struct {
+		name    string
+		advance time.Duration
+		rate    float64 // diferente de zero: nova taxa antes da retirada
+		take    float64
+		want    time.Duration
+	}{
+		{"dívida inicial", 0, 0, 50, 500 * time.Millisecond},
+		{"parte da dívida paga", 200 * time.Millisecond, 0, 0, 300 * time.Millisecond},
+		{"dívida quitada", time.Second, 0, 0, 0},
+		{"saldo acumulado", 0, 0, 170, time.Second},
+		{"saldo limitado a um segundo", 10 * time.Second, 0, 150, 500 * time.Millisecond},
+		{"nova taxa zera o saldo", 0, 50, 25, 500 * time.Millisecond},
+		{"retirada sem dívida", 2 * time.Second, 0, 25, 0},
+	}
+	for _, st := range steps {
+		now = now.Add(st.advance)
+		if st.rate != 0 {
+			b.setRate(st.rate)
+		}
+		if got := b.reserve(st.take); got != st.want {
+			t.Errorf("%s: espera = %v, esperado %v", st.name, got, st.want)
+		}
+	}
+}
+
+func TestTokenBucket(t *testing.T) {
+	// Taxa zero desativa o limite: nenhuma retirada espera.
+	var unlimited tokenBucket
+	unlimited.setRate(0)
+	if got := unlimited.reserve(1e12); got != 0 {
+		t.Errorf("espera sem limite = %v, esperado 0", got)
+	}
+	if err := unlimited.take(context.Background(), 1e12); err != nil {
+		t.Errorf("take sem limite: %v", err)
+	}
+
+	// Quem está em dívida desiste quando a operação é cancelada.
+	var limited tokenBucket
+	limited.setRate(1)
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if err := limited.take(ctx, 100); !errors.Is(err, context.Canceled) {
+		t.Errorf("erro = %v, esperado %v", err, context.Canceled)
+	}
+}
@@ -0,0 +1,508 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+//================================================================//
+// MONITORAMENTO CONTÍNUO (WATCH)
+//================================================================//
+
+// fsEvent é uma alteração observada no sistema de arquivos. Overflow indica
+// que eventos foram perdidos e a árvore precisa ser varrida novamente.
+type fsEvent struct {
+	Path     string
+	IsDir    bool
+	Removed  bool
+	Overflow bool
+}
+
+// fsWatcher abstrai o mecanismo de notificação de cada plataforma.
+type fsWatcher interface {
+	Add(dir string) error
+	Events() <-chan fsEvent
+	Close() error
+}
+
+const (
+	// watchDebounce é o intervalo sem novos eventos antes de aplicar as alterações.
+	watchDebounce = time.Second
+	// watchMaxDelay limita a espera quando os eventos não param de chegar.
+	watchMaxDelay = 10 * time.Second
+)
+
+// WatchStatus resume o estado do monitoramento em andamento.
+type WatchStatus struct {
+	Active     bool      `json:"active"`
+	Profile    string    `json:"profile"`
+	SourceRoot string    `json:"source_root"`
+	DestRoot   string    `json:"dest_root"`
+	Files      int       `json:"files"`
+	Applied    int64     `json:"applied"`
+	Rescans    int       `json:"rescans"`
+	LastSync   time.Time `json:"last_sync"`
+}
+
+// watchSession mantém a CollectionReport da origem em memória e replica
+// cada alteração para o destino.
+type watchSession struct {
+	ctx     context.Context
+	profile Profile
+	watcher fsWatcher
+
+	mu     sync.Mutex
+	report CollectionReport
+	index  map[string]int // caminho relativo -> posição em report.Files
+	status WatchStatus
+	// maintenance pede a limpeza dos relatórios entre dois ciclos.
+	maintenance chan struct{}
+}
+
+var (
+	currentWatchMu sync.Mutex
+	currentWatch   *watchSession
+)
+
+// WatchProfile monitora a origem do perfil até a operação ser cancelada.
+func WatchProfile(ctx context.Context, p Profile) error {
+	watcher, err := newFSWatcher()
+	if err != nil {
+		return err
+	}
+	defer watcher.Close()
+
+	ws := &watchSession{
+		ctx:         ctx,
+		profile:     p,
+		watcher:     watcher,
+		status:      WatchStatus{Active: true, Profile: p.Name, SourceRoot: p.SourcePath, DestRoot: p.DestPath},
+		maintenance: make(chan struct{}, 1),
+	}
+	currentWatchMu.Lock()
+	currentWatch = ws
+	currentWatchMu.Unlock()
+	defer func() {
+		currentWatchMu.Lock()
+		currentWatch = nil
+		currentWatchMu.Unlock()
+	}()
+
+	if err := ws.rescan(ctx); err != nil {
+		return err
+	}
+	sendLog("log.watch.monitoring", "path", p.SourcePath)
+
+	var batch watchBatch
+	debounce := time.NewTimer(watchDebounce)
+	debounce.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+
+		case ev, ok := <-watcher.Events():
+			if !ok {
+				return errors.New("o monitor de arquivos foi encerrado inesperadamente")
+			}
+			debounce.Reset(batch.add(ev, time.Now()))
+
+		case <-debounce.C:
+			if err := checkPauseAndCancel(ctx); err != nil {
+				return err
+			}
+			pending, rescan := batch.take()
+			if rescan {
+				sendWarn("log.watch.overflow")
+				if err := ws.rescan(ctx); err != nil {
+					return err
+				}
+			} else {
+				ws.applyChanges(pending)
+			}
+
+		case <-ws.maintenance:
+			if err := cleanupReports(ctx, false); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+// watchBatch acumula os eventos recebidos entre dois ciclos do monitoramento.
+// Vários eventos do mesmo caminho resultam em uma única alteração.
+type watchBatch struct {
+	pending    map[string]fsEvent
+	needRescan bool // houve estouro da fila: o ciclo refaz a varredura completa
+	first      time.Time
+}
+
+// add registra ev, recebido em now, e retorna quanto esperar antes de aplicar
+// o lote: watchDebounce sem novos eventos, mas nunca mais que watchMaxDelay
+// após o primeiro evento do lote.
+func (b *watchBatch) add(ev fsEvent, now time.Time) time.Duration {
+	if ev.Overflow {
+		b.needRescan = true
+	} else {
+		if b.pending == nil {
+			b.pending = make(map[string]fsEvent)
+		}
+		b.pending[ev.Path] = ev
+	}
+	if b.first.IsZero() {
+		b.first = now
+	}
+	return max(min(watchDebounce, watchMaxDelay-now.Sub(b.first)), 0)
+}
+
+// take retorna as alterações acumuladas e se é preciso varrer a origem de
+// novo, esvaziando o lote.
+func (b *watchBatch) take() (map[string]fsEvent, bool) {
+	pending, rescan := b.pending, b.needRescan
+	*b = watchBatch{}
+	return pending, rescan
+}
+
+// requestWatchMaintenance pede ao monitoramento em andamento, se houver, que
+// rode a limpeza dos relatórios entre dois ciclos.
+func requestWatchMaintenance() {
+	currentWatchMu.Lock()
+	defer currentWatchMu.Unlock()
+	if currentWatch == nil {
+		return
+	}
+	select {
+	case currentWatch.maintenance <- struct{}{}:
+	default: // já pedida
+	}
+}
+
+// addRecursive registra dir e todos os seus subdiretórios não excluídos no watcher.
+func (ws *watchSession) addRecursive(dir string) error {
+	return filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
+		if err != nil || !info.IsDir() {
+			return nil
+		}
+		relPath, _ := filepath.Rel(ws.profile.SourcePath, path)
+		if relPath != "." && ws.profile.isExcluded(relPath) {
+			return filepath.SkipDir
+		}
+		return ws.watcher.Add(path)
+	})
+}
+
+// rescan registra novamente os diretórios no watcher, refaz a coleta completa
+// da origem e reconcilia o destino.
+func (ws *watchSession) rescan(ctx context.Context) error {
+	if err := ws.addRecursive(ws.profile.SourcePath); err != nil {
+		return err
+	}
+	var files []FileMetadata
+	err := collectMetadata(ctx, ws.profile.SourcePath, ws.profile.SyncOptions, func(f FileMetadata) error {
+		files = append(files, f)
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	ws.mu.Lock()
+	ws.report = CollectionReport{
+		Type:      "source",
+		RootPath:  ws.profile.SourcePath,
+		HashMode:  ws.profile.HashMode,
+		Files:     files,
+		Timestamp: time.Now(),
+	}
+	ws.index = make(map[string]int, len(files))
+	for i, f := range files {
+		ws.index[f.Path] = i
+	}
+	ws.status.Rescans++
+	ws.mu.Unlock()
+
+	// Copia tudo que estiver ausente ou desatualizado no destino.
+	copied := 0
+	for _, f := range files {
+		if err := checkPauseAndCancel(ctx); err != nil {
+			return err
+		}
+		if destUpToDate(filepath.Join(ws.profile.DestPath, f.Path), f) {
+			continue
+		}
+		if err := throttle.WaitFile(ctx); err != nil {
+			return err
+		}
+		if err := ws.copyToDest(f); err != nil {
+			sendError(CodeCopyFailed, f.Path, "log.copy_failed", "path", f.Path, "error", err)
+			continue
+		}
+		copied++
+	}
+
+	// Aplica a política de exclusão aos arquivos que existem apenas no destino.
+	removed := 0
+	if ws.profile.DeletionPolicy != "keep" {
+		var orphans []string
+		walkIncluded(ws.profile.DestPath, ws.profile.SyncOptions, func(path string, info os.FileInfo) error {
+			relPath, _ := filepath.Rel(ws.profile.DestPath, path)
+			if _, ok := ws.lookup(relPath); !ok {
+				orphans = append(orphans, relPath)
+			}
+			return nil
+		})
+		for _, relPath := range orphans {
+			if err := ws.removeFromDest(relPath); err != nil {
+				sendError(CodeDeleteFailed, relPath, "log.delete_failed", "path", relPath, "error", err)
+				continue
+			}
+			removed++
+		}
+	}
+
+	ws.mu.Lock()
+	ws.status.Files = len(ws.report.Files)
+	ws.status.LastSync = time.Now()
+	ws.mu.Unlock()
+	sendLog("log.watch.rescanned", "files", len(files), "copied", copied, "removed", removed)
+	sendProgressUpdate("status.watch.monitoring")
+	return nil
+}
+
+// applyChanges replica para o destino as alterações acumuladas durante o debounce.
+func (ws *watchSession) applyChanges(pending map[string]fsEvent) {
+	for path, ev := range pending {
+		relPath, err := filepath.Rel(ws.profile.SourcePath, path)
+		if err != nil || strings.HasPrefix(relPath, "..") || ws.profile.isExcluded(relPath) {
+			continue
+		}
+
+		info, err := os.Lstat(path)
+		if err == nil && info.Mode()&os.ModeSymlink != 0 {
+			switch ws.profile.SymlinkPolicy {
+			case "skip":
+				continue
+			case "follow":
+				// Links quebrados ou para fora dos diretórios permitidos são
+				// copiados como links. Alterações dentro de diretórios alcançados
+				// por links só aparecem na próxima varredura.
+				if _, target, err := followLink(path); err == nil {
+					info = target
+				}
+			}
+		}
+		switch {
+		case err != nil && errors.Is(err, os.ErrNotExist):
+			ws.handleRemoved(relPath)
+		case err != nil:
+			sendError(CodeStatFailed, relPath, "log.path_error", "path", path, "error", err)
+		case info.IsDir():
+			// Diretório criado ou movido para dentro da origem: monitora e copia o conteúdo.
+			if ev.IsDir && path != ws.profile.SourcePath {
+				if err := ws.addRecursive(path); err != nil {
+					sendError(CodeWatchFailed, relPath, "log.error", "error", err)
+				}
+				walkIncluded(path, ws.profile.SyncOptions, func(p string, _ os.FileInfo) error {
+					rel, _ := filepath.Rel(ws.profile.SourcePath, p)
+					if !ws.profile.isExcluded(rel) {
+						ws.handleChanged(p)
+					}
+					return nil
+				})
+			}
+		default:
+			ws.handleChanged(path)
+		}
+	}
+
+	ws.mu.Lock()
+	ws.status.Files = len(ws.report.Files)
+	ws.status.LastSync = time.Now()
+	ws.report.Timestamp = ws.status.LastSync
+	ws.mu.Unlock()
+}
+
+func (ws *watchSession) handleChanged(path string) {
+	if err := throttle.WaitFile(ws.ctx); err != nil {
+		return
+	}
+	meta, err := collectFile(ws.ctx, ws.profile.SourcePath, path, ws.profile.SyncOptions)
+	if err != nil {
+		sendFileError(CodeStatFailed, path, err)
+		return
+	}
+	if old, ok := ws.lookup(meta.Path); ok && old.Size == meta.Size && old.ModTime.Equal(meta.ModTime) && old.Hash == meta.Hash &&
+		destUpToDate(filepath.Join(ws.profile.DestPath, meta.Path), meta) {
+		return
+	}
+	if err := ws.copyToDest(meta); err != nil {
+		sendError(CodeCopyFailed, meta.Path, "log.copy_failed", "path", meta.Path, "error", err)
+		return
+	}
+
+	ws.mu.Lock()
+	if i, ok := ws.index[meta.Path]; ok {
+		ws.report.Files[i] = meta
+	} else {
+		ws.index[meta.Path] = len(ws.report.Files)
+		ws.report.Files = append(ws.report.Files, meta)
+	}
+	ws.status.Applied++
+	ws.mu.Unlock()
+
+	state.IncrementProcessed()
+	processed, _ := state.GetProgress()
+	state.SetTotal(processed)
+	sendProgressUpdate("status.watch.synced", "path", meta.Path)
+}
+
+// handleRemoved retira o arquivo (ou todos os arquivos do diretório) da
+// coleta em memória e aplica a política de exclusão no destino.
+func (ws *watchSession) handleRemoved(relPath string) {
+	ws.mu.Lock()
+	var removed []string
+	prefix := relPath + string(filepath.Separator)
+	for p := range ws.index {
+		if p == relPath || strings.HasPrefix(p, prefix) {
+			removed = append(removed, p)
+		}
+	}
+	for _, p := range removed {
+		ws.deleteLocked(p)
+	}
+	ws.mu.Unlock()
+
+	if ws.profile.DeletionPolicy == "keep" {
+		return
+	}
+	for _, p := range removed {
+		if err := ws.removeFromDest(p); err != nil && !errors.Is(err, os.ErrNotExist) {
+			sendError(CodeDeleteFailed, p, "log.delete_failed", "path", p, "error", err)
+			continue
+		}
+		sendLog("log.watch.removed", "policy", ws.profile.DeletionPolicy, "path", p)
+	}
+}
+
+// deleteLocked remove uma entrada da coleta mantendo o índice consistente.
+// Deve ser chamado com ws.mu travado.
+func (ws *watchSession) deleteLocked(relPath string) {
+	i, ok := ws.index[relPath]
+	if !ok {
+		return
+	}
+	last := len(ws.report.Files) - 1
+	if i != last {
+		ws.report.Files[i] = ws.report.Files[last]
+		ws.index[ws.report.Files[i].Path] = i
+	}
+	ws.report.Files = ws.report.Files[:last]
+	delete(ws.index, relPath)
+}
+
+func (ws *watchSession) lookup(relPath string) (FileMetadata, bool) {
+	ws.mu.Lock()
+	defer ws.mu.Unlock()
+	i, ok := ws.index[relPath]
+	if !ok {
+		return FileMetadata{}, false
+	}
+	return ws.report.Files[i], true
+}
+
+func (ws *watchSession) copyToDest(f FileMetadata) error {
+	dst := filepath.Join(ws.profile.DestPath, f.Path)
+	if f.Special != "" {
+		// Arquivos especiais ficam apenas registrados, como na cópia.
+		return nil
+	}
+	if err := copyEntry(ws.ctx, filepath.Join(ws.profile.SourcePath, f.Path), ws.profile.DestPath, dst, f, ws.profile.Copy.Preserve); err != nil {
+		return err
+	}
+	if ws.profile.Copy.Verify && f.Hash != "" && f.LinkTarget == "" {
+		return verifyHash(ws.ctx, dst, f.Hash)
+	}
+	return nil
+}
+
+func (ws *watchSession) removeFromDest(relPath string) error {
+	trashDir := filepath.Join(ws.profile.DestPath, trashDirName, time.Now().Format("20060102_150405"))
+	return removeFromDest(ws.profile.DestPath, relPath, ws.profile.DeletionPolicy, trashDir)
+}
+
+func (ws *watchSession) Status() WatchStatus {
+	ws.mu.Lock()
+	defer ws.mu.Unlock()
+	return ws.status
+}
+
+// destUpToDate indica se o arquivo no destino tem o mesmo tamanho e data de
+// modificação ou, para links simbólicos, o mesmo destino. Arquivos especiais,
+// que não são copiados, são sempre considerados atualizados.
+func destUpToDate(destPath string, f FileMetadata) bool {
+	switch {
+	case f.Special != "":
+		return true
+	case f.LinkTarget != "":
+		target, err := os.Readlink(destPath)
+		return err == nil && target == f.LinkTarget
+	}
+	info, err := os.Stat(destPath)
+	if err != nil {
+		return false
+	}
+	return info.Size() == f.Size && info.ModTime().Truncate(time.Second).Equal(f.ModTime.Truncate(time.Second))
+}
+
+//================================================================//
+// HTTP
+//================================================================//
+
+// handleWatch inicia (POST) ou consulta (GET) o monitoramento contínuo.
+// O monitoramento é interrompido pelo botão Cancelar, como qualquer operação.
+func handleWatch(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		status := WatchStatus{}
+		currentWatchMu.Lock()
+		if currentWatch != nil {
+			status = currentWatch.Status()
+		}
+		currentWatchMu.Unlock()
+		writeJSON(w, http.StatusOK, status)
+	case http.MethodPost:
+		var req struct {
+			Profile string `json:"profile"`
+		}
+		if err := decodeJSON(w, r, &req); err != nil {
+			writeError(w, err)
+			return
+		}
+		p, ok := profiles.Get(req.Profile)
+		if !ok {
+			writeError(w, fmt.Errorf("perfil %q %w", req.Profile, errNotFound))
+			return
+		}
+		p, err := p.resolvePaths()
+		if err != nil {
+			writeError(w, err)
+			return
+		}
+		if _, err := validateDirectory("source_path", p.SourcePath); err != nil {
+			writeError(w, err)
+			return
+		}
+
+		startJob(w, "watch", &p.Throttle, func(ctx context.Context) error {
+			sendLog("log.watch.started", "profile", p.Name)
+			return WatchProfile(ctx, p)
+		})
+	}
+}
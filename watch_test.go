@@ -0,0 +1,189 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"testing"
+	"time"
+)
+
+func TestWatchBatch(t *testing.T) {
+	start := time.Date(2026, 3, 1, 10, 0, 0, 0, time.UTC)
+	var b watchBatch
+	steps := []struct {
+		at   time.Duration // desde o primeiro evento
+		ev   fsEvent
+		wait time.Duration
+	}{
+		{0, fsEvent{Path: "/o/a"}, watchDebounce},
+		{500 * time.Millisecond, fsEvent{Path: "/o/b"}, watchDebounce},
+		{time.Second, fsEvent{Path: "/o/a", Removed: true}, watchDebounce},
+		{9500 * time.Millisecond, fsEvent{Path: "/o/c"}, 500 * time.Millisecond},
+		{12 * time.Second, fsEvent{Path: "/o/d"}, 0}, // eventos contínuos: espera limitada a watchMaxDelay
+	}
+	for _, st := range steps {
+		if got := b.add(st.ev, start.Add(st.at)); got != st.wait {
+			t.Errorf("evento %s em %v: espera = %v, esperado %v", st.ev.Path, st.at, got, st.wait)
+		}
+	}
+	pending, rescan := b.take()
+	if rescan {
+		t.Error("varredura pedida sem estouro da fila")
+	}
+	var paths []string
+	for p := range pending {
+		paths = append(paths, p)
+	}
+	sort.Strings(paths)
+	if want := []string{"/o/a", "/o/b", "/o/c", "/o/d"}; !reflect.DeepEqual(paths, want) {
+		t.Errorf("caminhos = %q, esperado %q", paths, want)
+	}
+	if !pending["/o/a"].Removed {
+		t.Error("o último evento de /o/a deveria prevalecer")
+	}
+
+	// O lote seguinte recomeça a contagem e acumula o estouro da fila.
+	later := start.Add(time.Minute)
+	if got := b.add(fsEvent{Path: "/o/e"}, later); got != watchDebounce {
+		t.Errorf("espera do novo lote = %v, esperado %v", got, watchDebounce)
+	}
+	b.add(fsEvent{Overflow: true}, later.Add(time.Second))
+	if pending, rescan := b.take(); !rescan || len(pending) != 1 {
+		t.Errorf("take = %v, %v; esperado uma alteração e nova varredura", pending, rescan)
+	}
+	if pending, rescan := b.take(); rescan || pending != nil {
+		t.Errorf("lote não esvaziado: %v, %v", pending, rescan)
+	}
+}
+
+// fakeWatcher registra os diretórios monitorados, sem observar o disco.
+type fakeWatcher struct {
+	dirs   []string
+	events chan fsEvent
+}
+
+func (w *fakeWatcher) Add(dir string) error   { w.dirs = append(w.dirs, dir); return nil }
+func (w *fakeWatcher) Events() <-chan fsEvent { return w.events }
+func (w *fakeWatcher) Close() error           { return nil }
+
+// watchTree cria origem e destino num diretório permitido e retorna uma
+// sessão de monitoramento entre eles, com a política de exclusão "delete".
+func watchTree(t *testing.T) (*watchSession, *fakeWatcher) {
+	t.Helper()
+	base, err := filepath.EvalSymlinks(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	savedSandbox, savedHub := sandbox, hub
+	sandbox = &PathSandbox{roots: []string{base}}
+	hub = newHub()
+	t.Cleanup(func() { sandbox, hub = savedSandbox, savedHub })
+
+	files := map[string]string{
+		"origem/a":      "novo",
+		"origem/sub/b":  "b",
+		"origem/tmp/x":  "excluído",
+		"destino/a":     "antigo conteúdo",
+		"destino/orfao": "só no destino",
+		"destino/tmp/y": "excluído no destino",
+	}
+	for name, content := range files {
+		path := filepath.Join(base, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	opts := defaultSyncOptions()
+	opts.HashMode = "none"
+	opts.DeletionPolicy = "delete"
+	opts.Exclusions = []string{"tmp"}
+	w := &fakeWatcher{}
+	p := Profile{Name: "teste", SourcePath: filepath.Join(base, "origem"), DestPath: filepath.Join(base, "destino"), SyncOptions: opts}
+	return &watchSession{ctx: context.Background(), profile: p, watcher: w, index: map[string]int{}}, w
+}
+
+// treeFiles lista os arquivos de dir, com caminhos relativos.
+func treeFiles(t *testing.T, dir string) []string {
+	t.Helper()
+	var files []string
+	err := filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
+		if err == nil && !info.IsDir() {
+			rel, _ := filepath.Rel(dir, path)
+			files = append(files, filepath.ToSlash(rel))
+		}
+		return err
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return files
+}
+
+func TestWatchRescan(t *testing.T) {
+	ws, w := watchTree(t)
+	if err := ws.rescan(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	src := ws.profile.SourcePath
+	if want := []string{src, filepath.Join(src, "sub")}; !reflect.DeepEqual(w.dirs, want) {
+		t.Errorf("diretórios monitorados = %q, esperado %q", w.dirs, want)
+	}
+	if got, want := treeFiles(t, ws.profile.DestPath), []string{"a", "sub/b", "tmp/y"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("destino = %q, esperado %q", got, want)
+	}
+	if data, _ := os.ReadFile(filepath.Join(ws.profile.DestPath, "a")); string(data) != "novo" {
+		t.Errorf("destino/a = %q, esperado o conteúdo da origem", data)
+	}
+	if st := ws.Status(); st.Files != 2 || st.Rescans != 1 {
+		t.Errorf("status = %+v, esperado 2 arquivos e 1 varredura", st)
+	}
+	for _, p := range []string{"a", "sub/b"} {
+		if _, ok := ws.lookup(filepath.FromSlash(p)); !ok {
+			t.Errorf("%s ausente da coleta em memória", p)
+		}
+	}
+}
+
+func TestWatchApplyChanges(t *testing.T) {
+	ws, _ := watchTree(t)
+	if err := ws.rescan(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	src, dst := ws.profile.SourcePath, ws.profile.DestPath
+	if err := os.WriteFile(filepath.Join(src, "c"), []byte("c"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.RemoveAll(filepath.Join(src, "sub")); err != nil {
+		t.Fatal(err)
+	}
+
+	var batch watchBatch
+	now := time.Now()
+	for _, ev := range []fsEvent{
+		{Path: filepath.Join(src, "c")},
+		{Path: filepath.Join(src, "sub"), IsDir: true, Removed: true},
+		{Path: filepath.Join(src, "tmp", "novo")}, // excluído pelo perfil
+	} {
+		batch.add(ev, now)
+	}
+	pending, _ := batch.take()
+	ws.applyChanges(pending)
+
+	if got, want := treeFiles(t, dst), []string{"a", "c", "tmp/y"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("destino = %q, esperado %q", got, want)
+	}
+	if _, err := os.Stat(filepath.Join(dst, "sub", "b")); !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("sub/b não removido do destino: %v", err)
+	}
+	if st := ws.Status(); st.Files != 2 || st.Applied != 1 {
+		t.Errorf("status = %+v, esperado 2 arquivos e 1 alteração aplicada", st)
+	}
+}
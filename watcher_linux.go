@@ -0,0 +1,134 @@
+//go:build linux
+
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"syscall"
+	"unsafe"
+)
+
+// inotifyWatcher implementa fsWatcher usando diretamente a API inotify do Linux.
+type inotifyWatcher struct {
+	fd     int
+	file   *os.File
+	events chan fsEvent
+	done   chan struct{}
+
+	mu   sync.Mutex
+	dirs map[int32]string // watch descriptor -> diretório
+}
+
+const inotifyMask = syscall.IN_CREATE | syscall.IN_CLOSE_WRITE | syscall.IN_MODIFY |
+	syscall.IN_MOVED_FROM | syscall.IN_MOVED_TO | syscall.IN_DELETE |
+	syscall.IN_DELETE_SELF | syscall.IN_ATTRIB
+
+func newFSWatcher() (fsWatcher, error) {
+	fd, err := syscall.InotifyInit1(syscall.IN_CLOEXEC | syscall.IN_NONBLOCK)
+	if err != nil {
+		return nil, fmt.Errorf("inotify: %w", err)
+	}
+	// Como o descritor é não bloqueante, os.File usa o poller do runtime e
+	// Close desbloqueia a goroutine de leitura.
+	w := &inotifyWatcher{
+		fd:     fd,
+		file:   os.NewFile(uintptr(fd), "inotify"),
+		events: make(chan fsEvent, 1024),
+		done:   make(chan struct{}),
+		dirs:   make(map[int32]string),
+	}
+	go w.readEvents()
+	return w, nil
+}
+
+func (w *inotifyWatcher) Add(dir string) error {
+	wd, err := syscall.InotifyAddWatch(w.fd, dir, inotifyMask)
+	if err != nil {
+		return fmt.Errorf("inotify %s: %w", dir, err)
+	}
+	w.mu.Lock()
+	w.dirs[int32(wd)] = dir
+	w.mu.Unlock()
+	return nil
+}
+
+func (w *inotifyWatcher) Events() <-chan fsEvent { return w.events }
+
+func (w *inotifyWatcher) Close() error {
+	close(w.done)
+	return w.file.Close()
+}
+
+// send entrega o evento, a menos que o watcher já tenha sido fechado.
+func (w *inotifyWatcher) send(ev fsEvent) bool {
+	select {
+	case w.events <- ev:
+		return true
+	case <-w.done:
+		return false
+	}
+}
+
+func (w *inotifyWatcher) readEvents() {
+	defer close(w.events)
+	var buf [64 * (syscall.SizeofInotifyEvent + syscall.NAME_MAX + 1)]byte
+	for {
+		n, err := w.file.Read(buf[:])
+		if err != nil {
+			if !errors.Is(err, os.ErrClosed) {
+				w.send(fsEvent{Overflow: true})
+			}
+			return
+		}
+		for offset := 0; offset+syscall.SizeofInotifyEvent <= n; {
+			raw := (*syscall.InotifyEvent)(unsafe.Pointer(&buf[offset]))
+			nameBytes := buf[offset+syscall.SizeofInotifyEvent : offset+syscall.SizeofInotifyEvent+int(raw.Len)]
+			offset += syscall.SizeofInotifyEvent + int(raw.Len)
+
+			if raw.Mask&syscall.IN_Q_OVERFLOW != 0 {
+				if !w.send(fsEvent{Overflow: true}) {
+					return
+				}
+				continue
+			}
+
+			w.mu.Lock()
+			dir, ok := w.dirs[raw.Wd]
+			if raw.Mask&syscall.IN_IGNORED != 0 {
+				delete(w.dirs, raw.Wd)
+				ok = false
+			}
+			w.mu.Unlock()
+			if !ok {
+				continue
+			}
+
+			path := dir
+			if name := cString(nameBytes); name != "" {
+				path = filepath.Join(dir, name)
+			}
+			ev := fsEvent{
+				Path:    path,
+				IsDir:   raw.Mask&syscall.IN_ISDIR != 0,
+				Removed: raw.Mask&(syscall.IN_DELETE|syscall.IN_MOVED_FROM|syscall.IN_DELETE_SELF) != 0,
+			}
+			if !w.send(ev) {
+				return
+			}
+		}
+	}
+}
+
+// cString converte um nome terminado em NUL (com preenchimento) para string.
+func cString(b []byte) string {
+	for i, c := range b {
+		if c == 0 {
+			return string(b[:i])
+		}
+	}
+	return string(b)
+}
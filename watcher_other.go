@@ -0,0 +1,12 @@
+//go:build !linux
+
+package main
+
+import (
+	"errors"
+	"runtime"
+)
+
+func newFSWatcher() (fsWatcher, error) {
+	return nil, errors.New("monitoramento contínuo não suportado em " + runtime.GOOS)
+}
@@ -0,0 +1,104 @@
+package main
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"embed"
+	"encoding/hex"
+	"fmt"
+	"io/fs"
+	"log"
+	"net/http"
+	"path"
+	"strings"
+	"time"
+)
+
+//================================================================//
+// ARQUIVOS DO FRONTEND
+//================================================================//
+
+// webFiles contém a interface (HTML, CSS, JS e fontes), embutida no executável.
+//
+//go:embed web
+var webFiles embed.FS
+
+// webAsset é um arquivo do frontend já carregado em memória, com seu ETag.
+type webAsset struct {
+	content     []byte
+	etag        string
+	contentType string
+}
+
+// webContentTypes cobre as extensões usadas em web/; nem todas constam da
+// tabela de tipos MIME do sistema (ex.: .woff2).
+var webContentTypes = map[string]string{
+	".html":  "text/html; charset=utf-8",
+	".css":   "text/css; charset=utf-8",
+	".js":    "text/javascript; charset=utf-8",
+	".woff2": "font/woff2",
+	".txt":   "text/plain; charset=utf-8",
+}
+
+var webAssets = loadWebAssets()
+
+// loadWebAssets lê os arquivos embutidos uma única vez, calculando o ETag de
+// cada um a partir do conteúdo.
+func loadWebAssets() map[string]webAsset {
+	assets := make(map[string]webAsset)
+	err := fs.WalkDir(webFiles, "web", func(name string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() {
+			return err
+		}
+		content, err := webFiles.ReadFile(name)
+		if err != nil {
+			return err
+		}
+		sum := sha256.Sum256(content)
+		contentType, ok := webContentTypes[path.Ext(name)]
+		if !ok {
+			contentType = "application/octet-stream"
+		}
+		assets[strings.TrimPrefix(name, "web/")] = webAsset{
+			content:     content,
+			etag:        `"` + hex.EncodeToString(sum[:8]) + `"`,
+			contentType: contentType,
+		}
+		return nil
+	})
+	if err != nil {
+		log.Fatalf("Erro ao carregar os arquivos do frontend: %v", err)
+	}
+	return assets
+}
+
+// serveWebAsset envia um arquivo do frontend. As fontes nunca mudam de conteúdo
+// e podem ficar em cache por um ano; os demais arquivos são revalidados pelo
+// ETag a cada acesso, para que uma nova versão do executável seja vista logo.
+func serveWebAsset(w http.ResponseWriter, r *http.Request, name string) {
+	asset, ok := webAssets[name]
+	if !ok {
+		writeError(w, fmt.Errorf("%s %w", r.URL.Path, errNotFound))
+		return
+	}
+	h := w.Header()
+	h.Set("Content-Type", asset.contentType)
+	h.Set("ETag", asset.etag)
+	h.Set("X-Content-Type-Options", "nosniff")
+	if strings.HasPrefix(name, "static/fonts/") {
+		h.Set("Cache-Control", "public, max-age=31536000, immutable")
+	} else {
+		h.Set("Cache-Control", "no-cache")
+	}
+	http.ServeContent(w, r, "", time.Time{}, bytes.NewReader(asset.content))
+}
+
+// handleStatic serve os arquivos de web/static/ em /static/.
+func handleStatic(w http.ResponseWriter, r *http.Request) {
+	name := strings.TrimPrefix(r.URL.Path, "/")
+	if name != path.Clean(name) {
+		writeError(w, fmt.Errorf("%s %w", r.URL.Path, errNotFound))
+		return
+	}
+	serveWebAsset(w, r, name)
+}
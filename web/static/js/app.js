@@ -0,0 +1,513 @@
+// A página só é montada após o carregamento do catálogo de mensagens (ver i18n.js).
+document.addEventListener('DOMContentLoaded', () => i18n.load().then(() => {
+    const logs = document.getElementById('logs');
+    const progressBar = document.getElementById('progress-bar');
+    const progressText = document.getElementById('progress-text');
+
+    const btnPause = document.getElementById('btn-pause');
+    const btnResume = document.getElementById('btn-resume');
+    const btnCancel = document.getElementById('btn-cancel');
+    
+    const actionButtons = [
+        document.getElementById('collect-source'),
+        document.getElementById('collect-dest'),
+        document.getElementById('compare-jsons'),
+        document.getElementById('copy-files')
+    ];
+
+    const api = '/api/v1';
+    i18n.fillLanguageSelect(document.getElementById('language-select'));
+    let lastStatus = 'idle';
+    let currentJobId = '';
+    // Preenchidos por /auth/me; usuários "viewer" têm acesso somente leitura.
+    let csrfToken = '';
+    let readOnly = true;
+    // Eventos recebidos, mantidos para reaplicar o filtro de nível.
+    const maxEvents = 2000;
+    const levelOrder = { debug: 0, info: 1, warn: 2, error: 3 };
+    const levelSelect = document.getElementById('log-level');
+    const jobLogLink = document.getElementById('job-log-link');
+    let events = [];
+    // Reconecta automaticamente com espera exponencial (1s, 2s, 4s... até 30s).
+    let reconnectDelay = 1000;
+
+    function connect() {
+        const scheme = window.location.protocol === 'https:' ? 'wss://' : 'ws://';
+        const ws = new WebSocket(scheme + window.location.host + '/ws');
+        ws.onopen = () => {
+            reconnectDelay = 1000;
+            // O servidor reenvia o histórico recente e o progresso atual a cada conexão.
+            events = [];
+            logs.textContent = '';
+            appendEvents([localEvent('info', 'ui.connected')]);
+        };
+        ws.onclose = () => {
+            fetch('/auth/me').then(r => { if (r.status === 401) window.location.href = '/login'; });
+            appendEvents([localEvent('warn', 'ui.disconnected', { seconds: reconnectDelay / 1000 })]);
+            setControlsState('idle');
+            setTimeout(connect, reconnectDelay);
+            reconnectDelay = Math.min(reconnectDelay * 2, 30000);
+        };
+        ws.onmessage = handleMessage;
+    }
+
+    function localEvent(level, key, params) {
+        return { time: new Date().toISOString(), level: level, key: key, params: params };
+    }
+
+    // Eventos com chave são exibidos no idioma do usuário; os demais, como vieram do servidor.
+    function formatEvent(ev) {
+        const time = new Date(ev.time).toLocaleTimeString();
+        const message = ev.key ? i18n.t(ev.key, ev.params, ev.message) : ev.message;
+        return '[' + time + '] ' + (ev.level || 'info').toUpperCase().padEnd(5) + ' ' + message;
+    }
+
+    function visible(ev) {
+        return (levelOrder[ev.level] ?? 1) >= levelOrder[levelSelect.value];
+    }
+
+    function appendEvents(list) {
+        events = events.concat(list);
+        if (events.length > maxEvents) {
+            events = events.slice(events.length - maxEvents);
+            renderEvents();
+            return;
+        }
+        const lines = list.filter(visible).map(formatEvent);
+        if (lines.length === 0) return;
+        logs.textContent += lines.join('\n') + '\n';
+        logs.scrollTop = logs.scrollHeight;
+    }
+
+    function renderEvents() {
+        const lines = events.filter(visible).map(formatEvent);
+        logs.textContent = lines.length ? lines.join('\n') + '\n' : '';
+        logs.scrollTop = logs.scrollHeight;
+    }
+
+    levelSelect.addEventListener('change', renderEvents);
+
+    function formatBytes(bytes) {
+        const units = ['B', 'KB', 'MB', 'GB', 'TB'];
+        let i = 0;
+        while (bytes >= 1024 && i < units.length - 1) { bytes /= 1024; i++; }
+        return bytes.toFixed(i === 0 ? 0 : 1) + ' ' + units[i];
+    }
+
+    function formatDuration(seconds) {
+        seconds = Math.round(seconds);
+        const h = Math.floor(seconds / 3600), m = Math.floor((seconds % 3600) / 60), s = seconds % 60;
+        return [h, m, s].map(v => String(v).padStart(2, '0')).join(':');
+    }
+
+    function renderProgressDetails(data) {
+        const running = data.status === 'running' || data.status === 'paused';
+        document.getElementById('stat-bytes').textContent = data.total_bytes > 0
+            ? formatBytes(data.processed_bytes) + ' / ' + formatBytes(data.total_bytes) : '';
+        document.getElementById('stat-speed').textContent = running && data.bytes_per_second > 0
+            ? formatBytes(data.bytes_per_second) + '/s' : '';
+        document.getElementById('stat-eta').textContent = running && data.eta_seconds >= 0
+            ? i18n.t('ui.stats.remaining', { time: formatDuration(data.eta_seconds) }) : '';
+        document.getElementById('stat-errors').textContent = data.errors > 0 ? i18n.t('ui.stats.errors', { count: data.errors }) : '';
+
+        const workerList = document.getElementById('worker-list');
+        workerList.innerHTML = '';
+        (data.workers || []).forEach((file, i) => {
+            if (!file) return;
+            const item = document.createElement('li');
+            item.textContent = '#' + (i + 1) + ' ' + file;
+            workerList.appendChild(item);
+        });
+    }
+
+    function setControlsState(status) {
+        const isRunning = status === 'running';
+        const isPaused = status === 'paused';
+        const isIdle = status === 'idle' || status === 'finished' || status === 'canceled';
+
+        btnPause.style.display = isPaused ? 'none' : 'inline-block';
+        btnResume.style.display = isPaused ? 'inline-block' : 'none';
+
+        btnPause.disabled = !isRunning;
+        btnResume.disabled = !isPaused;
+        btnCancel.disabled = isIdle;
+
+        actionButtons.forEach(btn => btn.disabled = !isIdle);
+        document.getElementById('sync-profile').disabled = !isIdle;
+        document.getElementById('watch-profile').disabled = !isIdle;
+        if (readOnly) {
+            document.querySelectorAll('button:not(#logout)').forEach(btn => btn.disabled = true);
+        }
+    }
+
+    function handleMessage(event) {
+        const data = JSON.parse(event.data);
+
+        if (data.type === 'log') {
+            appendEvents([{ time: new Date().toISOString(), level: 'info', message: data.message }]);
+        } else if (data.type === 'logs') {
+            appendEvents(data.events || []);
+        } else if (data.type === 'progress') {
+            progressBar.value = data.percentage;
+            progressText.textContent = i18n.t(data.message_key, data.message_params, data.message) + ' (' + data.processed + ' / ' + data.total + ') - ' + data.percentage.toFixed(2) + '%';
+            renderProgressDetails(data);
+            if (data.job_id) {
+                currentJobId = data.job_id;
+                jobLogLink.href = api + '/jobs/' + encodeURIComponent(data.job_id) + '/log';
+                jobLogLink.style.display = 'inline';
+            }
+            if (data.status !== lastStatus && data.status === 'running') loadThrottle();
+            lastStatus = data.status;
+            setControlsState(data.status);
+            if (data.status === 'finished') loadSchedules();
+        }
+    }
+
+    function apiRequest(method, url, body = {}) {
+        return fetch(url, { method: method, headers: { 'X-CSRF-Token': csrfToken }, body: JSON.stringify(body) });
+    }
+
+    function postRequest(url, body = {}) {
+        return apiRequest('POST', url, body);
+    }
+
+    // showError exibe a mensagem do corpo JSON de erro da API.
+    function showError(r) {
+        return r.json()
+            .then(e => alert(e.message + (e.field ? ' (' + e.field + ')' : '')))
+            .catch(() => alert(i18n.t('ui.http_error', { status: r.status })));
+    }
+
+    function deleteRequest(url) {
+        return fetch(url, { method: 'DELETE', headers: { 'X-CSRF-Token': csrfToken } });
+    }
+
+    fetch('/auth/me').then(r => r.json()).then(me => {
+        csrfToken = me.csrf_token;
+        readOnly = me.role !== 'operator';
+        document.getElementById('current-user').textContent = me.username + ' ' + i18n.t(readOnly ? 'ui.role.viewer' : 'ui.role.operator');
+        setControlsState(lastStatus);
+        loadSchedules();
+    });
+
+    document.getElementById('logout').addEventListener('click', () => {
+        postRequest('/logout').then(() => { window.location.href = '/login'; });
+    });
+
+    actionButtons.forEach(btn => {
+        btn.addEventListener('click', (e) => {
+            let url, body;
+            switch(e.target.id) {
+                case 'collect-source':
+                    url = api + '/jobs/collect';
+                    body = { path: document.getElementById('source-path').value, type: 'source', profile: profileSelect.value };
+                    break;
+                case 'collect-dest':
+                    url = api + '/jobs/collect';
+                    body = { path: document.getElementById('dest-path').value, type: 'destination', profile: profileSelect.value };
+                    break;
+                case 'compare-jsons':
+                    url = api + '/jobs/compare';
+                    body = { source_file: document.getElementById('source-json').value, dest_file: document.getElementById('dest-json').value };
+                    break;
+                case 'copy-files':
+                     url = api + '/jobs/copy';
+                     body = { comparison_file: document.getElementById('comparison-json').value, profile: profileSelect.value };
+                     break;
+            }
+            if ((body.path === '' && !body.profile) || body.source_file === '' || body.comparison_file === '') {
+                alert(i18n.t('ui.required_fields'));
+                return;
+            }
+            postRequest(url, body).then(r => {
+                if (!r.ok) showError(r);
+            });
+        });
+    });
+
+    // O visualizador abre numa nova aba e também está disponível para usuários somente leitura.
+    document.getElementById('view-comparison').addEventListener('click', () => {
+        const name = document.getElementById('comparison-json').value.trim();
+        if (name === '') {
+            alert(i18n.t('ui.required_fields'));
+            return;
+        }
+        window.open('/viewer?name=' + encodeURIComponent(name), '_blank');
+    });
+
+    const MB = 1024 * 1024;
+    const throttleState = document.getElementById('throttle-state');
+
+    function showThrottle(t) {
+        document.getElementById('throttle-mbps').value = (t.bytes_per_second || 0) / MB;
+        document.getElementById('throttle-fps').value = t.files_per_second || 0;
+        throttleState.textContent = t.limited_now ? '' : i18n.t('ui.throttle.full_speed');
+    }
+
+    function loadThrottle() {
+        fetch(api + '/settings/throttle').then(r => r.json()).then(showThrottle);
+    }
+
+    // Altera os limites da operação em andamento, preservando os horários em velocidade total.
+    document.getElementById('apply-throttle').addEventListener('click', () => {
+        fetch(api + '/settings/throttle').then(r => r.json()).then(current => {
+            const body = {
+                bytes_per_second: Math.round(parseFloat(document.getElementById('throttle-mbps').value || '0') * MB),
+                files_per_second: parseFloat(document.getElementById('throttle-fps').value || '0'),
+                full_speed_windows: current.full_speed_windows || []
+            };
+            return apiRequest('PUT', api + '/settings/throttle', body);
+        }).then(r => r.ok ? r.json().then(showThrottle) : showError(r));
+    });
+
+    loadThrottle();
+
+    const profileSelect = document.getElementById('profile-select');
+    const scheduleProfile = document.getElementById('schedule-profile');
+    let profileList = [];
+
+    function loadProfiles() {
+        return fetch(api + '/profiles').then(r => r.json()).then(list => {
+            profileList = list;
+            const selected = profileSelect.value;
+            profileSelect.innerHTML = '';
+            const none = document.createElement('option');
+            none.value = '';
+            none.textContent = i18n.t('ui.profiles.none');
+            profileSelect.appendChild(none);
+            scheduleProfile.innerHTML = '';
+            list.forEach(p => {
+                [profileSelect, scheduleProfile].forEach(select => {
+                    const option = document.createElement('option');
+                    option.value = p.name;
+                    option.textContent = p.name;
+                    select.appendChild(option);
+                });
+            });
+            profileSelect.value = selected;
+        });
+    }
+
+    // Preenche todos os cards com os dados do perfil selecionado.
+    function applyProfile(p) {
+        document.getElementById('profile-name').value = p ? p.name : '';
+        document.getElementById('profile-source').value = p ? p.source_path : '';
+        document.getElementById('profile-dest').value = p ? p.dest_path : '';
+        document.getElementById('profile-exclusions').value = p && p.exclusions ? p.exclusions.join(', ') : '';
+        document.getElementById('profile-hash').value = p ? p.hash_mode : 'sha256';
+        document.getElementById('profile-xattrs').checked = p ? !!p.xattrs : false;
+        document.getElementById('profile-deletion').value = p ? p.deletion_policy : 'keep';
+        document.getElementById('profile-symlinks').value = p && p.symlink_policy ? p.symlink_policy : 'link';
+        document.getElementById('profile-overwrite').checked = p ? p.copy_options.overwrite : true;
+        document.getElementById('profile-verify').checked = p ? p.copy_options.verify : false;
+        document.getElementById('profile-fix-metadata').checked = p ? !!p.copy_options.fix_metadata : false;
+        const preserve = p && p.copy_options.preserve ? p.copy_options.preserve : {};
+        ['mode', 'owner', 'atime', 'xattrs'].forEach(k => {
+            document.getElementById('profile-preserve-' + k).checked = !!preserve[k];
+        });
+        const t = p && p.throttle ? p.throttle : {};
+        document.getElementById('profile-mbps').value = (t.bytes_per_second || 0) / MB;
+        document.getElementById('profile-fps').value = t.files_per_second || 0;
+        document.getElementById('profile-windows').value = (t.full_speed_windows || []).map(w => w.start + '-' + w.end).join(', ');
+        if (p) {
+            document.getElementById('source-path').value = p.source_path;
+            document.getElementById('dest-path').value = p.dest_path;
+            scheduleProfile.value = p.name;
+        }
+    }
+
+    profileSelect.addEventListener('change', () => {
+        applyProfile(profileList.find(p => p.name === profileSelect.value));
+    });
+
+    document.getElementById('save-profile').addEventListener('click', () => {
+        const body = {
+            name: document.getElementById('profile-name').value,
+            source_path: document.getElementById('profile-source').value,
+            dest_path: document.getElementById('profile-dest').value,
+            exclusions: document.getElementById('profile-exclusions').value.split(',').map(e => e.trim()).filter(e => e !== ''),
+            hash_mode: document.getElementById('profile-hash').value,
+            xattrs: document.getElementById('profile-xattrs').checked,
+            deletion_policy: document.getElementById('profile-deletion').value,
+            symlink_policy: document.getElementById('profile-symlinks').value,
+            copy_options: {
+                overwrite: document.getElementById('profile-overwrite').checked,
+                verify: document.getElementById('profile-verify').checked,
+                fix_metadata: document.getElementById('profile-fix-metadata').checked,
+                preserve: {
+                    mode: document.getElementById('profile-preserve-mode').checked,
+                    owner: document.getElementById('profile-preserve-owner').checked,
+                    atime: document.getElementById('profile-preserve-atime').checked,
+                    xattrs: document.getElementById('profile-preserve-xattrs').checked
+                }
+            },
+            throttle: {
+                bytes_per_second: Math.round(parseFloat(document.getElementById('profile-mbps').value || '0') * MB),
+                files_per_second: parseFloat(document.getElementById('profile-fps').value || '0'),
+                full_speed_windows: document.getElementById('profile-windows').value.split(',')
+                    .map(w => w.trim()).filter(w => w !== '')
+                    .map(w => { const [start, end] = w.split('-').map(x => x.trim()); return { start, end }; })
+            }
+        };
+        postRequest(api + '/profiles', body).then(r => {
+            if (!r.ok) { showError(r); return; }
+            loadProfiles().then(() => {
+                profileSelect.value = body.name;
+                applyProfile(profileList.find(p => p.name === body.name));
+            });
+        });
+    });
+
+    document.getElementById('delete-profile').addEventListener('click', () => {
+        if (!profileSelect.value) return;
+        deleteRequest(api + '/profiles/' + encodeURIComponent(profileSelect.value)).then(r => {
+            if (!r.ok) { showError(r); return; }
+            profileSelect.value = '';
+            applyProfile(null);
+            loadProfiles();
+        });
+    });
+
+    document.getElementById('sync-profile').addEventListener('click', () => {
+        if (!profileSelect.value) {
+            alert(i18n.t('ui.profiles.select_first'));
+            return;
+        }
+        postRequest(api + '/jobs/sync', { profile: profileSelect.value }).then(r => {
+            if (!r.ok) showError(r);
+        });
+    });
+
+    document.getElementById('watch-profile').addEventListener('click', () => {
+        if (!profileSelect.value) {
+            alert(i18n.t('ui.profiles.select_first'));
+            return;
+        }
+        postRequest(api + '/jobs/watch', { profile: profileSelect.value }).then(r => {
+            if (!r.ok) showError(r);
+        });
+    });
+
+    loadProfiles();
+
+    // Seletor de diretórios: navega pelos diretórios permitidos via /fs/list
+    // e preenche o campo indicado em data-target do botão "Procurar...".
+    const picker = document.getElementById('folder-picker');
+    const pickerList = document.getElementById('picker-list');
+    let pickerTarget = null;
+    let pickerListing = null;
+
+    function openPicker(path) {
+        fetch(api + '/fs/list?path=' + encodeURIComponent(path)).then(r => {
+            if (!r.ok) {
+                // Caminho digitado inválido ou fora dos diretórios permitidos: começa pelas raízes.
+                if (path) return openPicker('');
+                return showError(r);
+            }
+            return r.json().then(renderPicker);
+        });
+    }
+
+    function renderPicker(listing) {
+        pickerListing = listing;
+        document.getElementById('picker-path').textContent = listing.path || i18n.t('ui.browse.roots');
+        document.getElementById('picker-up').disabled = !listing.path;
+        document.getElementById('picker-select').disabled = !listing.path;
+        document.getElementById('picker-note').textContent = listing.truncated
+            ? i18n.t('ui.browse.truncated', { count: listing.entries.length }) : '';
+        pickerList.innerHTML = '';
+        if (listing.entries.length === 0) {
+            const item = document.createElement('li');
+            item.textContent = i18n.t('ui.browse.empty');
+            pickerList.appendChild(item);
+        }
+        listing.entries.forEach(entry => {
+            const item = document.createElement('li');
+            item.textContent = listing.path ? entry.name : entry.path;
+            const details = document.createElement('span');
+            details.className = 'details';
+            details.textContent = entry.error
+                ? i18n.t('ui.browse.unreadable')
+                : i18n.t('ui.browse.summary', { files: entry.files, dirs: entry.dirs, size: formatBytes(entry.size) });
+            item.appendChild(details);
+            if (entry.error) {
+                item.className = 'unreadable';
+            } else {
+                item.addEventListener('click', () => openPicker(entry.path));
+            }
+            pickerList.appendChild(item);
+        });
+        if (!picker.open) picker.showModal();
+    }
+
+    document.querySelectorAll('button.browse').forEach(btn => {
+        btn.addEventListener('click', () => {
+            pickerTarget = document.getElementById(btn.dataset.target);
+            openPicker(pickerTarget.value.trim());
+        });
+    });
+
+    document.getElementById('picker-up').addEventListener('click', () => openPicker(pickerListing.parent || ''));
+    document.getElementById('picker-select').addEventListener('click', () => {
+        pickerTarget.value = pickerListing.path;
+        picker.close();
+    });
+    document.getElementById('picker-cancel').addEventListener('click', () => picker.close());
+
+    const scheduleList = document.getElementById('schedule-list');
+
+    function formatDate(value) {
+        if (!value || value.startsWith('0001-')) return '-';
+        return new Date(value).toLocaleString();
+    }
+
+    function loadSchedules() {
+        fetch(api + '/schedules').then(r => r.json()).then(schedules => {
+            scheduleList.innerHTML = '';
+            schedules.forEach(s => {
+                const row = document.createElement('tr');
+                [s.name, s.profile, s.cron, formatDate(s.next_run), formatDate(s.last_run), s.last_result || '-'].forEach(text => {
+                    const cell = document.createElement('td');
+                    cell.textContent = text;
+                    row.appendChild(cell);
+                });
+                const actions = document.createElement('td');
+                const del = document.createElement('button');
+                del.textContent = i18n.t('ui.schedules.delete');
+                del.disabled = readOnly;
+                del.addEventListener('click', () => {
+                    deleteRequest(api + '/schedules/' + encodeURIComponent(s.name)).then(loadSchedules);
+                });
+                actions.appendChild(del);
+                row.appendChild(actions);
+                scheduleList.appendChild(row);
+            });
+        });
+    }
+
+    document.getElementById('save-schedule').addEventListener('click', () => {
+        const body = {
+            name: document.getElementById('schedule-name').value,
+            profile: document.getElementById('schedule-profile').value,
+            cron: document.getElementById('schedule-cron').value,
+            overlap: document.getElementById('schedule-overlap').value,
+            enabled: true
+        };
+        postRequest(api + '/schedules', body).then(r => {
+            if (!r.ok) { showError(r); return; }
+            loadSchedules();
+        });
+    });
+
+    loadSchedules();
+    setInterval(loadSchedules, 30000);
+
+    [['pause', btnPause], ['resume', btnResume], ['cancel', btnCancel]].forEach(([action, btn]) => {
+        btn.addEventListener('click', () => postRequest(api + '/jobs/' + encodeURIComponent(currentJobId) + '/' + action).then(r => {
+            if (!r.ok) showError(r);
+        }));
+    });
+    
+    setControlsState('idle');
+    connect();
+}));
@@ -0,0 +1,64 @@
+// Traduz a interface com o catálogo de /api/v1/i18n. O servidor escolhe o
+// idioma pelo cookie "lang" (preferência do usuário) ou pelo Accept-Language.
+const i18n = (() => {
+    const languageNames = { 'pt-BR': 'Português (Brasil)', 'en': 'English' };
+    let messages = {};
+    let language = document.documentElement.lang;
+    let languages = [];
+
+    // t traduz key, substituindo os parâmetros {nome}. Parâmetros que também são
+    // mensagens ({key, params}) são traduzidos no mesmo idioma. Sem tradução,
+    // usa fallback (ex.: o texto já renderizado pelo servidor) ou a própria chave.
+    function t(key, params, fallback) {
+        const text = messages[key];
+        if (text === undefined) return fallback ?? key;
+        return text.replace(/\{(\w+)\}/g, (match, name) => {
+            const value = params ? params[name] : undefined;
+            if (value === undefined || value === null) return match;
+            if (typeof value === 'object' && value.key) return t(value.key, value.params);
+            return String(value);
+        });
+    }
+
+    // apply traduz os elementos marcados com data-i18n (texto) e data-i18n-placeholder.
+    function apply(root = document) {
+        root.querySelectorAll('[data-i18n]').forEach(el => { el.textContent = t(el.dataset.i18n, null, el.textContent); });
+        root.querySelectorAll('[data-i18n-placeholder]').forEach(el => { el.placeholder = t(el.dataset.i18nPlaceholder, null, el.placeholder); });
+        document.documentElement.lang = language;
+    }
+
+    // load busca o catálogo e traduz a página. Em caso de falha, a página
+    // permanece no idioma original do HTML.
+    function load() {
+        return fetch('/api/v1/i18n')
+            .then(r => r.json())
+            .then(catalog => {
+                messages = catalog.messages || {};
+                language = catalog.language;
+                languages = catalog.languages || [];
+                apply();
+            })
+            .catch(() => {});
+    }
+
+    // setLanguage grava a preferência e recarrega a página já traduzida.
+    function setLanguage(lang) {
+        document.cookie = 'lang=' + encodeURIComponent(lang) + '; path=/; max-age=31536000; SameSite=Strict';
+        window.location.reload();
+    }
+
+    // fillLanguageSelect preenche select com os idiomas disponíveis.
+    function fillLanguageSelect(select) {
+        select.innerHTML = '';
+        languages.forEach(lang => {
+            const option = document.createElement('option');
+            option.value = lang;
+            option.textContent = languageNames[lang] || lang;
+            select.appendChild(option);
+        });
+        select.value = language;
+        select.addEventListener('change', () => setLanguage(select.value));
+    }
+
+    return { t, apply, load, setLanguage, fillLanguageSelect };
+})();
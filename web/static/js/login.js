@@ -0,0 +1,7 @@
+const error = document.getElementById('error');
+if (location.search.includes('erro=limite')) {
+    error.dataset.i18n = 'ui.login.locked';
+    error.textContent = 'Muitas tentativas de login. Aguarde alguns minutos e tente novamente.';
+}
+if (location.search.includes('erro=')) error.style.display = 'block';
+i18n.load();
@@ -0,0 +1,146 @@
+// Visualizador de relatórios de comparação: totais por diretório numa árvore
+// expansível. Os arquivos de cada diretório aparecem ao expandi-lo.
+document.addEventListener('DOMContentLoaded', () => i18n.load().then(() => {
+    const name = new URLSearchParams(window.location.search).get('name') || '';
+    const message = document.getElementById('viewer-message');
+    // Categorias de diferença: campo do resumo por diretório e lista do relatório.
+    const categories = [
+        { key: 'missing', list: 'missing_in_dest' },
+        { key: 'different', list: 'different_in_dest' },
+        { key: 'only_in_dest', list: 'only_in_dest' },
+        { key: 'metadata_changed', list: 'metadata_changed' }
+    ];
+    let subdirs = {};
+    let filesByDir = {};
+
+    document.getElementById('report-name').textContent = name;
+    if (!name) {
+        message.textContent = i18n.t('ui.viewer.no_report');
+        return;
+    }
+    fetch('/api/v1/reports/comparison/' + encodeURIComponent(name)).then(r => {
+        if (r.ok) return r.json().then(render);
+        return r.json()
+            .then(e => { message.textContent = e.message; })
+            .catch(() => { message.textContent = i18n.t('ui.http_error', { status: r.status }); });
+    });
+
+    function formatBytes(bytes) {
+        const units = ['B', 'KB', 'MB', 'GB', 'TB'];
+        let i = 0;
+        while (bytes >= 1024 && i < units.length - 1) { bytes /= 1024; i++; }
+        return bytes.toFixed(i === 0 ? 0 : 1) + ' ' + units[i];
+    }
+
+    function parentOf(path) {
+        const i = path.lastIndexOf('/');
+        return i < 0 ? '.' : path.slice(0, i);
+    }
+
+    function baseName(path) {
+        return path.slice(path.lastIndexOf('/') + 1);
+    }
+
+    // rollup monta o resumo por diretório de relatórios gravados antes de ele
+    // fazer parte do relatório.
+    function rollup(report) {
+        const dirs = {};
+        categories.forEach(c => (report[c.list] || []).forEach(f => {
+            let dir = parentOf(f.path);
+            for (;;) {
+                const d = dirs[dir] || (dirs[dir] = { path: dir });
+                d[c.key] = d[c.key] || { files: 0, bytes: 0 };
+                d[c.key].files++;
+                d[c.key].bytes += f.size;
+                if (dir === '.') break;
+                dir = parentOf(dir);
+            }
+        }));
+        return Object.values(dirs);
+    }
+
+    function render(report) {
+        document.getElementById('source-root').textContent = report.source_root;
+        document.getElementById('destination-root').textContent = report.destination_root;
+        const dirs = report.directories || rollup(report);
+        dirs.forEach(d => {
+            if (d.path === '.') return;
+            (subdirs[parentOf(d.path)] = subdirs[parentOf(d.path)] || []).push(d);
+        });
+        categories.forEach(c => (report[c.list] || []).forEach(f => {
+            const dir = parentOf(f.path);
+            (filesByDir[dir] = filesByDir[dir] || []).push({ file: f, category: c.key });
+        }));
+        const root = dirs.find(d => d.path === '.');
+        if (!root) {
+            message.textContent = i18n.t('ui.viewer.no_differences');
+            return;
+        }
+        message.hidden = true;
+        document.getElementById('diff-tree').hidden = false;
+        const item = dirItem(root, 0);
+        document.getElementById('tree-root').appendChild(item);
+        toggle(item, root, 0);
+    }
+
+    function row(className, label, depth) {
+        const div = document.createElement('div');
+        div.className = 'row ' + className;
+        const nameCell = document.createElement('span');
+        nameCell.className = 'name';
+        nameCell.textContent = label;
+        nameCell.title = label;
+        nameCell.style.paddingLeft = (depth * 20) + 'px';
+        div.appendChild(nameCell);
+        return div;
+    }
+
+    function dirItem(dir, depth) {
+        const item = document.createElement('li');
+        const label = dir.path === '.' ? i18n.t('ui.viewer.root') : baseName(dir.path) + '/';
+        const div = row('dir', label, depth);
+        categories.forEach(c => {
+            const cell = document.createElement('span');
+            cell.className = c.key;
+            // Relatórios anteriores à categoria metadata_changed não a trazem.
+            const total = dir[c.key];
+            if (total && total.files > 0) cell.textContent = i18n.t('ui.viewer.count', { files: total.files, size: formatBytes(total.bytes) });
+            div.appendChild(cell);
+        });
+        div.addEventListener('click', () => toggle(item, dir, depth));
+        item.appendChild(div);
+        return item;
+    }
+
+    function fileItem(entry, depth) {
+        const item = document.createElement('li');
+        const div = row('file', baseName(entry.file.path), depth);
+        categories.forEach(c => {
+            const cell = document.createElement('span');
+            cell.className = c.key;
+            if (c.key === entry.category) cell.textContent = formatBytes(entry.file.size);
+            div.appendChild(cell);
+        });
+        item.appendChild(div);
+        return item;
+    }
+
+    // toggle expande ou recolhe um diretório. O conteúdo é montado na primeira
+    // expansão, para que relatórios grandes abram rapidamente.
+    function toggle(item, dir, depth) {
+        let children = item.querySelector(':scope > ul');
+        if (!children) {
+            children = document.createElement('ul');
+            (subdirs[dir.path] || [])
+                .sort((a, b) => a.path.localeCompare(b.path))
+                .forEach(d => children.appendChild(dirItem(d, depth + 1)));
+            (filesByDir[dir.path] || [])
+                .sort((a, b) => a.file.path.localeCompare(b.file.path))
+                .forEach(f => children.appendChild(fileItem(f, depth + 1)));
+            item.appendChild(children);
+        } else {
+            children.hidden = !children.hidden;
+        }
+        item.firstChild.classList.toggle('open', !children.hidden);
+    }
+}));